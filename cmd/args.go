@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// normalize prepares command line for parsing.
+//
+// Flags of the command with subcommands are moved before its arguments.
+// cli does the same for commands without subcommands only, so that e.g. dump logs -d logs.json works as well.
+// Standalone - and negative numbers are glued to the preceding flag of any command,
+// e.g. -d - becomes --dump=- and --rps -1 becomes --requests-per-second=-1,
+// otherwise cli takes them for an argument or a flag and the next argument for the flag value.
+// Other commands are left as they are.
+func normalize(app *cli.App, args []string) []string {
+	if len(args) < 2 {
+		return args
+	}
+
+	i := 1
+	for i < len(args) && strings.HasPrefix(args[i], "-") && args[i] != "-" {
+		if f := lookup(app.Flags, args[i]); f != nil && takesValue(f, args[i]) {
+			i++
+		}
+		i++
+	}
+	if i >= len(args) {
+		return args
+	}
+
+	cmd := app.Command(args[i])
+	if cmd == nil {
+		return args
+	}
+	for i+1 < len(args) {
+		sub := subcommand(cmd, args[i+1])
+		if sub == nil {
+			break
+		}
+		cmd, i = sub, i+1
+	}
+	reorder := len(cmd.Subcommands) > 0
+
+	flags, positional := []string{}, []string{}
+	for j := i + 1; j < len(args); j++ {
+		arg := args[j]
+		switch {
+		case arg == "--":
+			positional = append(positional, args[j:]...)
+			j = len(args)
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			positional = append(positional, arg)
+		default:
+			var next []string
+			if j+1 < len(args) {
+				next = args[j+1 : j+2]
+			}
+			tokens, n := flag(cmd, arg, next)
+			j += n
+			if reorder {
+				flags = append(flags, tokens...)
+			} else {
+				// cli reorders flags of the command itself.
+				positional = append(positional, tokens...)
+			}
+		}
+	}
+
+	normalized := append([]string{}, args[:i+1]...)
+	normalized = append(normalized, flags...)
+
+	return append(normalized, positional...)
+}
+
+// flag returns tokens of the flag argument followed by the next argument if any
+// and reports how many of the following arguments it consumed.
+func flag(cmd *cli.Command, arg string, next []string) ([]string, int) {
+	fs := expand(cmd, arg)
+	if fs == nil {
+		// unknown flags are left to cli.
+		return []string{arg}, 0
+	}
+	last := fs[len(fs)-1]
+	if !takesValue(last, arg) || len(next) == 0 {
+		return []string{arg}, 0
+	}
+	if !glued(next[0]) {
+		return []string{arg, next[0]}, 1
+	}
+
+	tokens := make([]string, 0, len(fs))
+	for _, f := range fs[:len(fs)-1] {
+		tokens = append(tokens, "--"+name(f))
+	}
+
+	return append(tokens, "--"+name(last)+"="+next[0]), 1
+}
+
+// glued reports whether flag value is taken by cli for something else
+// and must be glued to its flag.
+func glued(v string) bool {
+	if v == "-" {
+		return true
+	}
+	if !strings.HasPrefix(v, "-") {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+
+	return err == nil
+}
+
+// subcommand returns subcommand of the command with given name if any.
+func subcommand(cmd *cli.Command, name string) *cli.Command {
+	for i := range cmd.Subcommands {
+		if cmd.Subcommands[i].HasName(name) {
+			return &cmd.Subcommands[i]
+		}
+	}
+
+	return nil
+}
+
+// expand returns flags given argument refers to.
+// Combined short flags, e.g. -pd, refer to several flags,
+// all of them but the last one are boolean.
+func expand(cmd *cli.Command, arg string) []cli.Flag {
+	if f := lookup(cmd.Flags, arg); f != nil {
+		return []cli.Flag{f}
+	}
+	if !cmd.UseShortOptionHandling || strings.HasPrefix(arg, "--") || strings.Contains(arg, "=") {
+		return nil
+	}
+
+	shorts := strings.TrimPrefix(arg, "-")
+	fs := make([]cli.Flag, 0, len(shorts))
+	for k, short := range shorts {
+		f := lookup(cmd.Flags, "-"+string(short))
+		if f == nil || (k < len(shorts)-1 && takesValue(f, arg)) {
+			return nil
+		}
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+// lookup returns flag given argument refers to.
+func lookup(flags []cli.Flag, arg string) cli.Flag {
+	key := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+	for _, f := range flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if strings.TrimSpace(n) == key {
+				return f
+			}
+		}
+	}
+
+	return nil
+}
+
+// takesValue reports whether flag argument is followed by a value.
+func takesValue(f cli.Flag, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	switch f.(type) {
+	case cli.BoolFlag, cli.BoolTFlag:
+		return false
+	default:
+		return true
+	}
+}
+
+func name(f cli.Flag) string { return strings.TrimSpace(strings.Split(f.GetName(), ",")[0]) }
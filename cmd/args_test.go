@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestNormalize(t *testing.T) {
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "context, ctx"},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:                   "dump",
+			UseShortOptionHandling: true,
+			Subcommands:            []cli.Command{{Name: "inspect"}},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dump, d"},
+				cli.BoolFlag{Name: "plain, p"},
+				cli.IntFlag{Name: "slices"},
+			},
+		},
+		{
+			Name:                   "restore",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{Name: "dump, d"},
+				cli.StringSliceFlag{Name: "index-map, m"},
+				cli.BoolFlag{Name: "no-create"},
+			},
+		},
+		{
+			Name: "task",
+			Subcommands: []cli.Command{{
+				Name:                   "rethrottle",
+				UseShortOptionHandling: true,
+				Flags: []cli.Flag{
+					cli.Float64Flag{Name: "requests-per-second, rps"},
+				},
+			}},
+		},
+		{Name: "get"},
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"no command", "esctl", "esctl"},
+		{"global flags only", "esctl --ctx prod", "esctl --ctx prod"},
+		{"unknown command", "esctl nope -d -", "esctl nope -d -"},
+		{"command without flags", "esctl get index x", "esctl get index x"},
+		{"subcommand", "esctl dump inspect logs.json", "esctl dump inspect logs.json"},
+		{"flags after arguments", "esctl dump logs -d logs.json", "esctl dump -d logs.json logs"},
+		{"global flags", "esctl --ctx prod dump logs -d logs.json", "esctl --ctx prod dump -d logs.json logs"},
+		{"stdout", "esctl dump logs -d - --slices 2", "esctl dump --dump=- --slices 2 logs"},
+		{"combined short flags", "esctl dump logs -pd -", "esctl dump --plain --dump=- logs"},
+		{"combined short flags with file", "esctl dump logs -pd logs.json", "esctl dump -pd logs.json logs"},
+		{"unknown flag", "esctl dump logs -x -d a", "esctl dump -x -d a logs"},
+		{"flag with value", "esctl dump logs --dump=-", "esctl dump --dump=- logs"},
+		{"double dash", "esctl dump logs -- -d", "esctl dump logs -- -d"},
+		{"stdin", "esctl restore -d -", "esctl restore --dump=-"},
+		{"stdin keeps order", "esctl restore idx -d - -m a=b", "esctl restore idx --dump=- -m a=b"},
+		{"bool flag", "esctl restore idx --no-create -d -", "esctl restore idx --no-create --dump=-"},
+		{"no stdin", "esctl restore idx -d a.json", "esctl restore idx -d a.json"},
+		{"negative number", "esctl task rethrottle n:1 --rps -1", "esctl task rethrottle n:1 --requests-per-second=-1"},
+		{"negative fraction", "esctl task rethrottle --rps -0.5 n:1", "esctl task rethrottle --requests-per-second=-0.5 n:1"},
+		{"positive number", "esctl task rethrottle n:1 --rps 500", "esctl task rethrottle n:1 --rps 500"},
+		{"negative not a number", "esctl dump logs -d -p", "esctl dump -d -p logs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalize(app, strings.Fields(tt.args))
+			if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("normalize(%q) = %q, want %q", tt.args, got, want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/unqnown/esctl/internal/app"
 	"github.com/unqnown/esctl/internal/close"
 	"github.com/unqnown/esctl/internal/config"
+	"github.com/unqnown/esctl/internal/copy"
 	"github.com/unqnown/esctl/internal/create"
 	"github.com/unqnown/esctl/internal/delete"
 	"github.com/unqnown/esctl/internal/dump"
@@ -19,6 +20,7 @@ import (
 	"github.com/unqnown/esctl/internal/replace"
 	"github.com/unqnown/esctl/internal/reroute"
 	"github.com/unqnown/esctl/internal/restore"
+	"github.com/unqnown/esctl/internal/task"
 	"github.com/unqnown/esctl/internal/top"
 	"github.com/unqnown/esctl/internal/vacuum"
 	"github.com/unqnown/esctl/pkg/check"
@@ -74,6 +76,8 @@ func Run(ver string) error {
 		dump.Command,
 		restore.Command,
 		reindex.Command,
+		task.Command,
+		copy.Command,
 		vacuum.Command,
 		replace.Command,
 		open.Command,
@@ -82,7 +86,7 @@ func Run(ver string) error {
 		refresh.Command,
 	}
 
-	return app.Run(os.Args)
+	return app.Run(normalize(app, os.Args))
 }
 
 func _init(ctx *cli.Context) {
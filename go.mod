@@ -5,12 +5,13 @@ go 1.14
 require (
 	github.com/VividCortex/ewma v1.1.1 // indirect
 	github.com/go-yaml/yaml v2.1.0+incompatible
+	github.com/klauspost/compress v1.13.1
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
 	github.com/olekukonko/tablewriter v0.0.4
-	github.com/olivere/elastic/v7 v7.0.20
+	github.com/olivere/elastic/v7 v7.0.32
 	github.com/unqnown/semver v0.1.1-0.20200918234758-3b380562040b
 	github.com/urfave/cli v1.22.3
 	github.com/vbauerster/mpb v3.4.0+incompatible
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	github.com/xitongsys/parquet-go v1.6.2
 )
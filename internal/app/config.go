@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-yaml/yaml"
 	"github.com/unqnown/semver"
@@ -40,7 +41,41 @@ func NewConfig(ver semver.Version, home string) Config {
 	}
 }
 
-type Settings struct{}
+type Settings struct {
+	Bulk Bulk `yaml:"bulk,omitempty"`
+}
+
+// Bulk configures bulk processor. Zero values fall back to defaults.
+type Bulk struct {
+	Workers       int           `yaml:"workers,omitempty"`
+	Actions       int           `yaml:"actions,omitempty"`
+	Size          int           `yaml:"size,omitempty"`
+	FlushInterval time.Duration `yaml:"flush_interval,omitempty"`
+	// Adaptive enables backing off when cluster rejects documents
+	// and ramping up while it keeps up.
+	Adaptive bool `yaml:"adaptive,omitempty"`
+}
+
+// Merge overrides settings with non-zero values of o.
+func (b Bulk) Merge(o Bulk) Bulk {
+	if o.Workers != 0 {
+		b.Workers = o.Workers
+	}
+	if o.Actions != 0 {
+		b.Actions = o.Actions
+	}
+	if o.Size != 0 {
+		b.Size = o.Size
+	}
+	if o.FlushInterval != 0 {
+		b.FlushInterval = o.FlushInterval
+	}
+	if o.Adaptive {
+		b.Adaptive = true
+	}
+
+	return b
+}
 
 type Config struct {
 	Version semver.Version `yaml:"version"`
@@ -143,6 +178,17 @@ func (conf Config) Cluster() (Cluster, error) {
 	return cst, nil
 }
 
+// Bulk returns bulk processor settings of the current cluster.
+// Cluster settings override global ones.
+func (conf Config) Bulk() (Bulk, error) {
+	cst, err := conf.Cluster()
+	if err != nil {
+		return Bulk{}, err
+	}
+
+	return conf.Settings.Bulk.Merge(cst.Settings.Bulk), nil
+}
+
 func (conf Config) User() (User, error) {
 	ctx, err := conf.Ctx()
 	if err != nil {
@@ -176,11 +222,23 @@ type Context struct {
 }
 
 type Cluster struct {
-	Servers  []string `yaml:"servers"`
-	Settings Settings `yaml:"settings,omitempty"`
+	Servers []string `yaml:"servers"`
+	TLS     *TLS     `yaml:"tls,omitempty"`
+	// Headers are sent with every request to the cluster.
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Settings Settings          `yaml:"settings,omitempty"`
+}
+
+// TLS configures secure connection to the cluster.
+type TLS struct {
+	// CA is a path to PEM encoded certificate authorities trusted in addition to system ones.
+	CA       string `yaml:"ca,omitempty"`
+	Insecure bool   `yaml:"insecure,omitempty"`
 }
 
 type User struct {
 	Name     string `yaml:"name"`
 	Password string `yaml:"password"`
+	// APIKey is a base64 encoded id:api_key pair. It takes precedence over name and password.
+	APIKey string `yaml:"api_key,omitempty"`
 }
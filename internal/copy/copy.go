@@ -0,0 +1,200 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/backup"
+	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/pkg/check"
+	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/query"
+	"github.com/unqnown/esctl/pkg/transform"
+	"github.com/urfave/cli"
+)
+
+var Command = cli.Command{
+	Name:                   "copy",
+	Aliases:                []string{"cp"},
+	Usage:                  "Copies documents between clusters.",
+	Description:            `Streams documents from one context straight into another without intermediate dump and without reindex.remote.whitelist. Context may be omitted to refer to the current one. If destination index is omitted documents are copied to indices with the same names.`,
+	ArgsUsage:              "--from context/index --to context[/index] [--query query.json|'{\"term\":{...}}'|name] [--q 'field:value'] [--mappings]",
+	Category:               "Intermediate",
+	Action:                 run,
+	UseShortOptionHandling: true,
+	Flags: append(append(append([]cli.Flag{
+		cli.StringFlag{
+			Name:     "from, f",
+			Required: true,
+			Usage:    "Source `context/index`. Index may be a pattern",
+		},
+		cli.StringFlag{
+			Name:     "to, t",
+			Required: true,
+			Usage:    "Destination `context[/index]`. Index defaults to names of source indices",
+		},
+		cli.BoolFlag{
+			Name:  "mappings, m",
+			Usage: "Create destination indices with settings, mappings and aliases of source ones first",
+		},
+		cli.IntFlag{
+			Name:  "lpr",
+			Usage: "Documents limit per request",
+			Value: 1000,
+		},
+		cli.StringFlag{
+			Name:  "dead-letter",
+			Usage: "Write documents rejected by destination cluster to `FILE`. It can be restored as a dump",
+		},
+	}, query.Flags...), transform.Flags...), ctl.BulkFlags...),
+}
+
+// location refers to index of the context.
+type location struct {
+	ctx   string
+	index string
+}
+
+// parse parses location of form context/index.
+// Omitted context refers to the current one.
+// Location without slash is an index if bare is true, a context otherwise.
+func parse(loc string, bare bool) location {
+	i := strings.Index(loc, "/")
+	switch {
+	case i >= 0:
+		return location{ctx: loc[:i], index: loc[i+1:]}
+	case bare:
+		return location{index: loc}
+	default:
+		return location{ctx: loc}
+	}
+}
+
+func (l location) String() string { return l.ctx + "/" + l.index }
+
+func run(c *cli.Context) {
+	conf, err := app.Open(c.GlobalString("config"))
+	check.Fatalf(err, "open config: %v", err)
+
+	err = conf.SetContext(c.GlobalString("context"))
+	check.Fatal(err)
+
+	from, to := parse(c.String("from"), true), parse(c.String("to"), false)
+	if from.ctx == "" {
+		from.ctx = conf.Context
+	}
+	if to.ctx == "" {
+		to.ctx = conf.Context
+	}
+	if from.index == "" {
+		log.Fatal("source index not specified")
+	}
+
+	src, err := ctl.Connect(conf, from.ctx)
+	check.Fatalf(err, "source %q: %v", from.ctx, err)
+
+	dst, err := ctl.Connect(conf, to.ctx)
+	check.Fatalf(err, "destination %q: %v", to.ctx, err)
+
+	rename := func(index string) string { return index }
+	if to.index != "" {
+		rename = func(string) string { return to.index }
+	}
+
+	pipeline, err := transform.Parse(c)
+	check.Fatalf(err, "parse transformation: %v", err)
+
+	if c.Bool("mappings") {
+		indices, err := src.Indices(context.Background(), from.index)
+		check.Fatalf(err, "get source indices: %v", err)
+
+		err = dst.CreateIndices(context.Background(), indices, rename)
+		check.Fatalf(err, "copy mappings: %v", err)
+	}
+
+	dconf := conf
+	err = dconf.SetContext(to.ctx)
+	check.Fatal(err)
+
+	settings, err := ctl.Bulk(dconf, c)
+	check.Fatal(err)
+
+	processor, err := dst.Bulk(settings)
+	check.Fatalf(err, "start bulk processor: %v", err)
+
+	closeDL, err := ctl.DeadLetter(processor, c)
+	check.Fatalf(err, "create dead letter file: %v", err)
+	defer closeDL()
+
+	stop, err := ctl.Limit(processor, c)
+	check.Fatalf(err, "limit rate: %v", err)
+	defer stop()
+
+	scroll := src.Scroll(from.index).
+		Size(c.Int("lpr")).
+		FetchSource(true)
+
+	q, err := query.Parse(conf, c)
+	check.Fatalf(err, "parse query: %v", err)
+	if q != nil {
+		scroll.Query(q)
+	}
+
+	extra := ctl.Decorators(processor, settings, c)
+
+	copying, wait := bar.Docs(0, fmt.Sprintf("%s -> %s", from, to), extra...)
+
+	started := time.Now()
+
+	var total int64
+	for {
+		rsp, err := scroll.Do(context.Background())
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			check.Fatalf(err, "scroll: %v", err)
+		}
+
+		copying.SetTotal(rsp.TotalHits(), false)
+
+		for _, hit := range rsp.Hits.Hits {
+			doc := backup.Document{
+				ID:      hit.Id,
+				Index:   hit.Index,
+				Routing: hit.Routing,
+				Body:    hit.Source,
+			}
+
+			err = pipeline.Apply(&doc)
+			check.Fatalf(err, "transform %q: %v", hit.Id, err)
+
+			doc.Index = rename(doc.Index)
+
+			processor.Save(doc)
+		}
+		total += int64(len(rsp.Hits.Hits))
+
+		copying.IncrBy(len(rsp.Hits.Hits), time.Since(started))
+	}
+
+	if err := scroll.Clear(context.Background()); err != nil {
+		log.Printf("clear scroll: %v", err)
+	}
+
+	err = processor.Close()
+	check.Fatalf(err, "flush save tasks: %v", err)
+
+	copying.SetTotal(total, true)
+
+	wait()
+
+	err = ctl.Rejected(processor, c)
+	check.Fatalf(err, "copy: %v", err)
+}
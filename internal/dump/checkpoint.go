@@ -0,0 +1,77 @@
+package dump
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// checkpoint describes dump progress sufficient to resume it.
+type checkpoint struct {
+	// Slices holds read position of every dumped slice.
+	Slices []position `json:"slices"`
+	// Indices holds amount of dumped documents per index.
+	Indices map[string]int64 `json:"indices"`
+	// Docs is a total amount of dumped documents.
+	Docs int64 `json:"docs"`
+	// Estimated is a total amount of documents to dump.
+	Estimated int64 `json:"estimated"`
+	// Part is a number of the current part of chunked dump.
+	Part int `json:"part,omitempty"`
+	// PartDocs is an amount of documents in the current part.
+	PartDocs int64 `json:"part_docs,omitempty"`
+	// Offset is a size of the current dump file which contains only complete records.
+	Offset int64 `json:"offset"`
+}
+
+// position describes how far dump slice has been read.
+type position struct {
+	// PIT is an id of the point in time slice reads from.
+	PIT string `json:"pit"`
+	// After holds sort values of the last dumped document.
+	After []interface{} `json:"after,omitempty"`
+}
+
+// checkpointPath returns path of the dump checkpoint.
+// Checkpoint of chunked dump is stored within its directory.
+func checkpointPath(dump string) string {
+	if s, err := os.Stat(dump); err == nil && s.IsDir() {
+		return filepath.Join(dump, ".checkpoint")
+	}
+
+	return dump + ".checkpoint"
+}
+
+func openCheckpoint(path string) (cp checkpoint, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cp, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	// sort values may hold long numbers.
+	dec.UseNumber()
+
+	return cp, dec.Decode(&cp)
+}
+
+// save atomically replaces checkpoint file.
+func (cp checkpoint) save(path string) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -2,128 +2,797 @@ package dump
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/olivere/elastic/v7"
 	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/internal/dump/inspect"
+	"github.com/unqnown/esctl/internal/dump/verify"
 	"github.com/unqnown/esctl/pkg/backup"
 	"github.com/unqnown/esctl/pkg/bar"
 	"github.com/unqnown/esctl/pkg/check"
 	"github.com/unqnown/esctl/pkg/client"
 	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/query"
+	"github.com/unqnown/esctl/pkg/transform"
+	"github.com/unqnown/semver"
 	"github.com/urfave/cli"
+	"github.com/vbauerster/mpb"
 )
 
 var Command = cli.Command{
 	Name:                   "dump",
 	Aliases:                []string{"export"},
 	Usage:                  "Exports index content.",
-	Description:            `If no indices specified all indices will be exported.`,
-	ArgsUsage:              "[indices...] --dump path/to/dump.json [--lpr 1000] [--query path/to/query.json]",
+	Description:            `If no indices specified all indices will be exported. Dump manifest describes its source, indices settings, mappings, aliases and checksums. Interrupted dump can be continued with --resume. With --chunk-size dump is a directory of numbered part files. Besides esctl's own json documents can be dumped as _bulk request body, csv or parquet table. Parquet dump can't be resumed, csv and parquet dumps can't be restored. Documents can be restricted to some of their fields with --fields or --include and --exclude source filters. Sorted, limited and randomly sampled dumps are taken by a single slice.`,
+	ArgsUsage:              "[indices...] --dump path/to/dump.json [--lpr 1000] [--query query.json|'{\"term\":{...}}'|name] [--q 'field:value'] [--compress gzip|zstd] [--slices 1] [--chunk-size 1GB|1000000docs] [--format json|bulk|csv|parquet] [--columns _id,field,nested.field] [--fields field,nested.field] [--include 'pattern*'] [--exclude 'pattern*'] [--sort field:desc] [--limit N] [--sample 0.01|N] [--resume]",
 	Action:                 ctl.NewAction(dump),
 	Category:               "Intermediate",
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Subcommands: []cli.Command{
+		inspect.Command,
+		verify.Command,
+	},
+	Flags: append(append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "dump, d",
-			Usage: "Dump `FILE`. By default exports to context's .backup dir.",
+			Usage: "Dump `FILE`, - for stdout. By default exports to context's .backup dir.",
 		},
 		cli.IntFlag{
 			Name:  "lpr",
 			Usage: "Documents limit per request",
 			Value: 1000,
 		},
-		cli.StringFlag{
-			Name:  "query, q",
-			Usage: "Query `FILE`",
-		},
 		cli.BoolFlag{
 			Name:  "plain, p",
 			Usage: "Dump in plain json",
 		},
-	},
+		cli.StringFlag{
+			Name:  "compress, z",
+			Usage: "Compress dump with `ALGORITHM`: gzip or zstd",
+		},
+		cli.IntFlag{
+			Name:  "slices",
+			Usage: "Number of slices dumped concurrently",
+			Value: 1,
+		},
+		cli.BoolFlag{
+			Name:  "resume, r",
+			Usage: "Resume interrupted dump from its checkpoint. Run with the same arguments",
+		},
+		cli.StringFlag{
+			Name:  "manifest",
+			Usage: "Save manifest to `FILE` instead of the one next to dump. Dump to stdout has manifest only if set",
+		},
+		cli.StringFlag{
+			Name:  "chunk-size",
+			Usage: "Rotate dump into numbered part files of `SIZE`, e.g. 1GB or 1000000docs. Dump is a directory then",
+		},
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "Encode documents as `FORMAT`: json, bulk, csv or parquet",
+			Value: backup.JSON,
+		},
+		cli.StringFlag{
+			Name:  "columns",
+			Usage: "Comma separated `FIELDS` of csv and parquet dump. _id, _index and _routing refer to document metadata. Defaults to _index, _id and fields of indices mappings",
+		},
+		cli.StringFlag{
+			Name:  "fields",
+			Usage: "Comma separated `FIELDS` to dump. Nested fields are dot separated",
+		},
+		cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "Dump only source fields matching `PATTERN`, e.g. user.*",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Do not dump source fields matching `PATTERN`",
+		},
+		cli.StringSliceFlag{
+			Name:  "sort",
+			Usage: "Sort documents by `field[:asc|desc]`. Repeatable",
+		},
+		cli.Int64Flag{
+			Name:  "limit",
+			Usage: "Dump at most `N` documents",
+		},
+		cli.StringFlag{
+			Name:  "sample",
+			Usage: "Dump random sample: `RATIO` of documents, e.g. 0.01, or N documents",
+		},
+		cli.StringFlag{
+			Name:  "keep-alive",
+			Usage: "Point in time keep alive `DURATION`. Interrupted dump resumes seamlessly within it",
+			Value: "10m",
+		},
+	}, query.Flags...), transform.Flags...),
 }
 
 func dump(conf app.Config, conn *client.Client, c *cli.Context) error {
-	scroll := conn.Scroll(c.Args()...).Size(c.Int("lpr")).
-		FetchSource(true)
+	q, err := query.Parse(conf, c)
+	check.Fatalf(err, "parse query: %v", err)
 
-	if query := c.String("query"); query != "" {
-		q, err := openQuery(query)
-		check.Fatalf(err, "open query: %v", err)
-		scroll.Query(q)
+	filter := backup.Filter{
+		Include: c.StringSlice("include"),
+		Exclude: c.StringSlice("exclude"),
+		Fields:  split(c.String("fields")),
+	}
+
+	sorts, err := order(c.StringSlice("sort"))
+	check.Fatal(err)
+
+	smp, err := parseSample(c.String("sample"))
+	check.Fatal(err)
+
+	limit := c.Int64("limit")
+	switch {
+	case limit < 0:
+		log.Fatal("limit must be positive")
+	case smp.n > 0 && len(sorts) > 0:
+		log.Fatal("sample of N documents is sorted randomly")
+	case smp.n > 0 && (limit == 0 || smp.n < limit):
+		limit = smp.n
+	}
+	if (len(sorts) > 0 || smp.ratio > 0 || smp.n > 0 || limit > 0) && c.Int("slices") > 1 {
+		log.Fatal("sorted, limited or sampled dump is taken by a single slice")
+	}
+
+	var chunk backup.Chunk
+	chunked := c.IsSet("chunk-size")
+	if chunked {
+		var err error
+		chunk, err = backup.ParseChunk(c.String("chunk-size"))
+		check.Fatal(err)
+	}
+
+	format := c.String("format")
+	switch format {
+	case backup.JSON, backup.Bulk, backup.CSV:
+	case backup.Parquet:
+		switch {
+		case c.Bool("resume"):
+			log.Fatal("parquet dump can't be resumed")
+		case c.String("compress") != "":
+			log.Fatal("parquet dump is compressed by its own")
+		case chunk.Bytes > 0:
+			log.Fatal("parquet dump is chunked by documents only")
+		}
+	default:
+		log.Fatalf("unsupported format %q", format)
 	}
 
 	file := c.String("dump")
+	if file == "" && c.Bool("resume") {
+		log.Fatal("dump file not specified")
+	}
+
+	stdout := file == "-"
+	if stdout && (c.Bool("resume") || chunked) {
+		log.Fatal("dump to stdout can be neither resumed nor chunked")
+	}
 	if file == "" {
-		file = filepath.Join(conf.Home, app.BackupDir, fmt.Sprintf("%s.json", time.Now().Format("2006-01-02T15:04:05")))
+		name := time.Now().Format("2006-01-02T15:04:05")
+		if !chunked {
+			name += backup.FormatExt(format) + backup.Ext(c.String("compress"))
+		}
+		file = filepath.Join(conf.Home, app.BackupDir, name)
+	}
+
+	if chunked {
+		// manifest of chunked dump is stored within its directory.
+		err := os.MkdirAll(file, os.ModePerm)
+		check.Fatalf(err, "create dump directory: %v", err)
 	}
 
-	f, err := newDumpFile(file)
-	check.Fatalf(err, "create dump file: %v", err)
-	defer f.Close()
+	mpath := c.String("manifest")
+	if mpath == "" && !stdout {
+		mpath = backup.ManifestPath(file)
+	}
 
-	enc := json.NewEncoder(f)
-	if !c.Bool("plain") {
-		enc.SetIndent("", "	")
+	d := dumper{
+		file:        file,
+		chunked:     chunked,
+		chunk:       chunk,
+		compression: c.String("compress"),
+		format:      format,
+		plain:       c.Bool("plain"),
+		limit:       limit,
 	}
 
+	pipeline, err := transform.Parse(c)
+	check.Fatalf(err, "parse transformation: %v", err)
+
 	var (
-		estimated int64
-		processed int64
+		cp     checkpoint
+		m      backup.Manifest
+		f      *os.File
+		resume = c.Bool("resume")
 	)
-	dumping, wait := bar.Docs(estimated, "dumping")
+	if resume {
+		if s, err := os.Stat(file); err == nil && s.IsDir() && !chunked {
+			log.Fatal("chunked dump resumes with --chunk-size")
+		}
+
+		cp, err = openCheckpoint(checkpointPath(file))
+		check.Fatalf(err, "open checkpoint: %v", err)
 
-	started := time.Now()
+		f, err = openDumpFile(d.part(cp.Part), cp.Offset)
+		check.Fatalf(err, "open dump file: %v", err)
+
+		if chunked {
+			err = d.truncate(cp.Part)
+			check.Fatalf(err, "remove incomplete parts: %v", err)
+		}
+
+		m, err = backup.OpenManifest(mpath)
+		check.Fatalf(err, "open manifest: %v", err)
+
+		log.Printf("resuming dump from %d documents", cp.Docs)
+	} else {
+		slices := c.Int("slices")
+		if slices < 1 {
+			log.Fatal("slices must be positive")
+		}
+		cp.Slices = make([]position, slices)
+		cp.Indices = make(map[string]int64)
+
+		if chunked {
+			parts, err := backup.Parts(file)
+			check.Fatalf(err, "list dump parts: %v", err)
+			if len(parts) > 0 {
+				log.Fatalf("dump directory %q already holds parts", file)
+			}
+		}
+
+		// cluster is described first, so unreachable cluster leaves no empty dump behind.
+		m, err = manifest(conf, conn, c, q)
+		check.Fatalf(err, "describe dump: %v", err)
+
+		if !filter.Empty() {
+			m.Filter = &filter
+		}
+		m.Transform = transform.Describe(c)
+		m.Sort, m.Limit, m.Sample = c.StringSlice("sort"), limit, c.String("sample")
+		if m.Sample != "" {
+			m.Seed = time.Now().UnixNano()
+		}
+		if backup.Tabular(format) {
+			m.Columns = columns(c.String("columns"), filter, m.Indices)
+		}
+
+		if stdout {
+			f = os.Stdout
+		} else {
+			f, err = newDumpFile(d.part(0))
+			check.Fatalf(err, "create dump file: %v", err)
+		}
 
-	rsp, err := scroll.Do(context.Background())
+		if mpath != "" {
+			err = m.Save(mpath)
+			check.Fatalf(err, "save manifest: %v", err)
+		}
+	}
+
+	d.columns = m.Columns
+
+	err = d.open(f, cp.Offset)
+	check.Fatalf(err, "compress dump: %v", err)
+	defer func() { d.f.Close() }()
+
+	if m.Sample != "" {
+		// random score is stable within a seed, so resumed dump keeps the order.
+		random := elastic.NewFunctionScoreQuery().
+			AddScoreFunc(elastic.NewRandomFunction().Seed(m.Seed).Field("_seq_no")).
+			BoostMode("replace")
+		if q != nil {
+			random.Query(q)
+		}
+		if smp.ratio > 0 {
+			// random score is uniform in [0, 1).
+			random.MinScore(1 - smp.ratio)
+		} else {
+			sorts = append(sorts, elastic.NewScoreSort())
+		}
+		q = random
+	}
+	// shard doc breaks ties, so every document has a unique position.
+	sorts = append(sorts, elastic.NewFieldSort("_shard_doc"))
+
+	lpr := c.Int("lpr")
+	if limit > 0 && limit < int64(lpr) {
+		lpr = int(limit)
+	}
+
+	cursors := make([]*client.Cursor, len(cp.Slices))
+	for i, pos := range cp.Slices {
+		src := elastic.NewSearchSource().
+			Size(lpr).
+			FetchSourceContext(elastic.NewFetchSourceContext(true).
+				Include(filter.Includes()...).
+				Exclude(filter.Exclude...)).
+			TrackTotalHits(true).
+			Version(true).
+			SeqNoAndPrimaryTerm(true).
+			SortBy(sorts...)
+		if q != nil {
+			src.Query(q)
+		}
+		if len(cp.Slices) > 1 {
+			src.Slice(elastic.NewSliceQuery().Id(i).Max(len(cp.Slices)))
+		}
+
+		cursors[i] = conn.Cursor(src, c.String("keep-alive"), c.Args()...)
+		cursors[i].PIT, cursors[i].After = pos.PIT, pos.After
+	}
+	if !resume {
+		// all slices share the same point in time.
+		err = cursors[0].Open(context.Background())
+		check.Fatalf(err, "open point in time: %v", err)
+
+		for i, cursor := range cursors {
+			cursor.PIT = cursors[0].PIT
+			// slice may be interrupted or run out of documents before its first page is written.
+			cp.Slices[i].PIT = cursor.PIT
+		}
+	}
+
+	dumping, wait := bar.Docs(cp.Estimated, "dumping")
+	dumping.IncrBy(int(cp.Docs))
+
+	d.pipeline = pipeline
+	d.cp = cp
+	if !stdout && format != backup.Parquet {
+		d.path = checkpointPath(file)
+	}
+	d.bar = dumping
+	d.started = time.Now()
+
+	if d.path != "" && !resume {
+		err = d.cp.save(d.path)
+		check.Fatalf(err, "save checkpoint: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i, cursor := range cursors {
+		wg.Add(1)
+		go func(slice int, cursor *client.Cursor) {
+			defer wg.Done()
+			d.run(slice, cursor, resume)
+		}(i, cursor)
+	}
+	wg.Wait()
+
+	err = d.enc.Close()
+	check.Fatalf(err, "write dump: %v", err)
+
+	err = d.w.Close()
+	check.Fatalf(err, "write dump: %v", err)
+
+	if mpath != "" {
+		var files []backup.File
+		if stdout {
+			files = append(files, d.out.describe())
+		} else {
+			for i := 0; i <= d.cp.Part; i++ {
+				desc, err := backup.Describe(d.part(i))
+				check.Fatalf(err, "describe dump file: %v", err)
+				files = append(files, desc)
+			}
+		}
+
+		complete(&m, d.cp, files)
+
+		err = m.Save(mpath)
+		check.Fatalf(err, "save manifest: %v", err)
+	}
+
+	dumping.SetTotal(max(d.cp.Estimated, d.cp.Docs), true)
+
+	wait()
+
+	closed := make(map[string]bool)
+	for _, cursor := range cursors {
+		if closed[cursor.PIT] {
+			continue
+		}
+		closed[cursor.PIT] = true
+		if err := cursor.Close(context.Background()); err != nil {
+			log.Printf("close point in time: %v", err)
+		}
+	}
+
+	if d.path != "" {
+		if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("remove checkpoint: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// pitVersion is the first elasticsearch version with point in time.
+var pitVersion = semver.V("7.12.0")
+
+// manifest describes dump about to be taken.
+func manifest(conf app.Config, conn *client.Client, c *cli.Context, q elastic.Query) (m backup.Manifest, err error) {
+	info, err := conn.Info(context.Background())
+	if err != nil {
+		return m, err
+	}
+	// version may have a suffix, e.g. 8.0.0-SNAPSHOT.
+	if v, err := semver.Parse(strings.SplitN(info.Version.Number, "-", 2)[0]); err == nil && v.Lt(pitVersion) {
+		return m, fmt.Errorf("elasticsearch %s has no point in time, dump requires 7.12 or later", info.Version.Number)
+	}
+
+	indices, err := conn.Indices(context.Background(), c.Args()...)
 	if err != nil {
-		if errors.Is(err, io.EOF) {
-			dumping.SetTotal(0, true)
-			wait()
+		return m, err
+	}
 
-			return nil
+	m = backup.Manifest{
+		Format:  backup.Format,
+		Esctl:   c.App.Version,
+		Created: time.Now(),
+		Source: backup.Source{
+			Context: conf.Context,
+			Cluster: info.Name,
+			UUID:    info.UUID,
+			Version: info.Version.Number,
+		},
+		Compression: c.String("compress"),
+		Indices:     indices,
+	}
+	if format := c.String("format"); format != backup.JSON {
+		m.Encoding = format
+	}
+
+	if q != nil {
+		src, err := q.Source()
+		if err != nil {
+			return m, err
+		}
+		if m.Query, err = json.Marshal(src); err != nil {
+			return m, err
 		}
-		check.Fatalf(err, "scroll: %v", err)
 	}
-	estimated = rsp.TotalHits()
 
-	for {
-		dumping.SetTotal(max(estimated, processed+int64(len(rsp.Hits.Hits))), false)
+	return m, nil
+}
 
-		for _, hit := range rsp.Hits.Hits {
-			err = enc.Encode(backup.Document{
-				ID:    hit.Id,
-				Index: hit.Index,
-				Body:  hit.Source,
-			})
-			check.Fatalf(err, "write dump page: %v", err)
-			processed++
+// complete fills manifest with results of the finished dump.
+func complete(m *backup.Manifest, cp checkpoint, files []backup.File) {
+	for name, docs := range cp.Indices {
+		ind := m.Indices[name]
+		ind.Docs = docs
+		m.Indices[name] = ind
+	}
 
-			dumping.IncrBy(1, time.Since(started))
+	completed := time.Now()
+
+	m.Docs = cp.Docs
+	m.Completed = &completed
+	m.Files = files
+}
+
+// dumper writes pages of concurrent slices into a single dump file
+// or into numbered parts of chunked dump.
+type dumper struct {
+	mu sync.Mutex
+
+	file        string
+	chunked     bool
+	chunk       backup.Chunk
+	compression string
+	format      string
+	columns     []string
+	plain       bool
+	limit       int64
+
+	f   *os.File
+	out *sink
+	w   *backup.Writer
+	enc backup.Encoder
+
+	pipeline transform.Pipeline
+	cp       checkpoint
+	path     string
+	bar      *mpb.Bar
+	started  time.Time
+}
+
+// part returns path of the dump part.
+func (d *dumper) part(n int) string {
+	if !d.chunked {
+		return d.file
+	}
+
+	return filepath.Join(d.file, backup.PartName(n, d.format, d.compression))
+}
+
+// open starts writing to the dump file at given offset.
+func (d *dumper) open(f *os.File, offset int64) error {
+	out := &sink{w: f, n: offset}
+	if f == os.Stdout {
+		// stdout can't be reread to compute checksum.
+		out.h = sha256.New()
+	}
+
+	w, err := backup.NewWriter(out, d.compression)
+	if err != nil {
+		return err
+	}
+
+	enc, err := backup.NewEncoder(w, d.format, backup.EncoderOptions{
+		Plain:   d.plain,
+		Columns: d.columns,
+		// resumed part already has its header.
+		Header: offset == 0,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.f, d.out, d.w, d.enc = f, out, w, enc
+
+	return nil
+}
+
+// rotate completes current part and starts the next one.
+// Checkpoint keeps pointing to the previous part until the page is written,
+// so new part is discarded if dump is interrupted in the middle of the page.
+func (d *dumper) rotate() error {
+	if err := d.enc.Close(); err != nil {
+		return err
+	}
+	if err := d.w.Close(); err != nil {
+		return err
+	}
+	if err := d.f.Close(); err != nil {
+		return err
+	}
+
+	d.cp.Part++
+	d.cp.PartDocs, d.cp.Offset = 0, 0
+
+	f, err := newDumpFile(d.part(d.cp.Part))
+	if err != nil {
+		return err
+	}
+
+	return d.open(f, 0)
+}
+
+// truncate removes parts written after the given one.
+func (d *dumper) truncate(part int) error {
+	parts, err := backup.Parts(d.file)
+	if err != nil {
+		return err
+	}
+	for _, p := range parts[min(part+1, len(parts)):] {
+		if err := os.Remove(p); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+func (d *dumper) run(slice int, cursor *client.Cursor, resume bool) {
+	first := len(cursor.After) == 0
 
-		rsp, err = scroll.Do(context.Background())
+	for {
+		rsp, err := cursor.Next(context.Background())
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				dumping.SetTotal(max(estimated, processed), true)
+				return
+			}
+			if resume && elastic.IsNotFound(err) {
+				// point in time has expired while dump was interrupted.
+				// Sort values are still valid for unchanged indices.
+				log.Printf("slice %d: point in time expired, documents changed since %s may be missed or duplicated",
+					slice, d.started.Format(time.RFC3339))
 
-				break
+				err = cursor.Open(context.Background())
+				check.Fatalf(err, "open point in time: %v", err)
+				resume = false
+
+				continue
 			}
-			check.Fatalf(err, "scroll: %v", err)
+			check.Fatalf(err, "search: %v", err)
+		}
+
+		if !d.write(slice, cursor, rsp, first) {
+			return
 		}
+		first = false
 	}
+}
 
-	wait()
+// write writes a page and checkpoints it atomically
+// so that checkpoint offset always points to the end of complete page.
+// It reports whether dump should go on.
+func (d *dumper) write(slice int, cursor *client.Cursor, rsp *elastic.SearchResult, first bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	return nil
+	if first {
+		d.cp.Estimated += rsp.TotalHits()
+		if d.limit > 0 && d.cp.Estimated > d.limit {
+			d.cp.Estimated = d.limit
+		}
+	}
+
+	hits := rsp.Hits.Hits
+	if d.limit > 0 && d.cp.Docs+int64(len(hits)) > d.limit {
+		hits = hits[:max(d.limit-d.cp.Docs, 0)]
+	}
+
+	d.bar.SetTotal(max(d.cp.Estimated, d.cp.Docs+int64(len(hits))), false)
+
+	for _, hit := range hits {
+		if d.chunked && d.chunk.Full(d.cp.Offset, d.cp.PartDocs) {
+			err := d.rotate()
+			check.Fatalf(err, "start dump part: %v", err)
+		}
+
+		doc := backup.Document{
+			ID:          hit.Id,
+			Index:       hit.Index,
+			Routing:     hit.Routing,
+			Version:     hit.Version,
+			SeqNo:       hit.SeqNo,
+			PrimaryTerm: hit.PrimaryTerm,
+			Body:        hit.Source,
+		}
+
+		err := d.pipeline.Apply(&doc)
+		check.Fatalf(err, "transform %q: %v", hit.Id, err)
+
+		err = d.enc.Encode(doc)
+		check.Fatalf(err, "write dump page: %v", err)
+		d.cp.Indices[hit.Index]++
+		d.cp.Docs++
+		d.cp.PartDocs++
+
+		d.bar.IncrBy(1, time.Since(d.started))
+	}
+
+	err := d.enc.Flush()
+	check.Fatalf(err, "write dump page: %v", err)
+
+	// compressed frame ends with page, so dump can be resumed right after it.
+	err = d.w.Flush()
+	check.Fatalf(err, "write dump page: %v", err)
+
+	d.cp.Slices[slice] = position{PIT: cursor.PIT, After: cursor.After}
+	d.cp.Offset = d.out.n
+
+	more := d.limit == 0 || d.cp.Docs < d.limit
+
+	if d.path == "" {
+		// dump to stdout can't be resumed.
+		return more
+	}
+
+	err = d.cp.save(d.path)
+	check.Fatalf(err, "save checkpoint: %v", err)
+
+	return more
+}
+
+// sink counts bytes written to the dump file.
+type sink struct {
+	w io.Writer
+	n int64
+	h hash.Hash
+}
+
+func (s *sink) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.n += int64(n)
+	if s.h != nil {
+		s.h.Write(p[:n])
+	}
+
+	return n, err
+}
+
+// describe describes written stream.
+func (s *sink) describe() backup.File {
+	f := backup.File{Name: "-", Size: s.n}
+	if s.h != nil {
+		f.SHA256 = hex.EncodeToString(s.h.Sum(nil))
+	}
+
+	return f
+}
+
+// columns parses columns of tabular dump.
+// By default document metadata and dumped fields of indices mappings are dumped.
+func columns(list string, filter backup.Filter, indices map[string]backup.Index) []string {
+	if list != "" {
+		return split(list)
+	}
+
+	cols := []string{backup.ColumnIndex, backup.ColumnID}
+	if len(filter.Fields) > 0 && len(filter.Include) == 0 {
+		return append(cols, filter.Fields...)
+	}
+	for _, field := range backup.Fields(indices) {
+		if filter.Keeps(field) {
+			cols = append(cols, field)
+		}
+	}
+
+	return cols
+}
+
+// split splits comma separated list.
+func split(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	items := strings.Split(list, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+
+	return items
+}
+
+// sample is a random sample size: either a ratio of documents or their number.
+type sample struct {
+	ratio float64
+	n     int64
+}
+
+func parseSample(s string) (smp sample, err error) {
+	if s == "" {
+		return smp, nil
+	}
+	if strings.Contains(s, ".") {
+		if smp.ratio, err = strconv.ParseFloat(s, 64); err != nil || smp.ratio <= 0 || smp.ratio >= 1 {
+			return smp, fmt.Errorf("malformed sample ratio %q: expected number between 0 and 1", s)
+		}
+		return smp, nil
+	}
+	if smp.n, err = strconv.ParseInt(s, 10, 64); err != nil || smp.n < 1 {
+		return smp, fmt.Errorf("malformed sample size %q: expected positive number", s)
+	}
+
+	return smp, nil
+}
+
+// order parses sort options of field[:asc|desc] form.
+func order(fields []string) ([]elastic.Sorter, error) {
+	sorts := make([]elastic.Sorter, 0, len(fields))
+	for _, f := range fields {
+		field, dir := f, "asc"
+		if i := strings.LastIndexByte(f, ':'); i >= 0 {
+			field, dir = f[:i], f[i+1:]
+		}
+		if field == "" || (dir != "asc" && dir != "desc") {
+			return nil, fmt.Errorf("malformed sort %q: expected field[:asc|desc]", f)
+		}
+		sorts = append(sorts, elastic.NewFieldSort(field).Order(dir == "asc"))
+	}
+
+	return sorts, nil
 }
 
 func max(vs ...int64) int64 {
@@ -139,20 +808,38 @@ func max(vs ...int64) int64 {
 	return max
 }
 
-func openQuery(path string) (elastic.Query, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
+func min(a, b int) int {
+	if a < b {
+		return a
 	}
-
-	return elastic.NewRawStringQuery(string(data)), nil
+	return b
 }
 
 func newDumpFile(path string) (*os.File, error) {
-	dir, _ := filepath.Split(path)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
 		return nil, err
 	}
 
 	return os.Create(path)
 }
+
+// openDumpFile opens existing dump file for appending.
+// Everything after offset is considered a partially written record and truncated.
+func openDumpFile(path string, offset int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return f, nil
+}
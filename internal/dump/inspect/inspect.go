@@ -0,0 +1,100 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unqnown/esctl/pkg/backup"
+	"github.com/unqnown/esctl/pkg/check"
+	"github.com/unqnown/esctl/pkg/table"
+	"github.com/urfave/cli"
+)
+
+var Command = cli.Command{
+	Name:                   "inspect",
+	Aliases:                []string{"describe"},
+	Usage:                  "Shows what dump contains.",
+	Description:            "Reads dump manifest only, dumped documents are not loaded.",
+	ArgsUsage:              "path/to/dump.json",
+	Action:                 inspect,
+	UseShortOptionHandling: true,
+}
+
+func inspect(c *cli.Context) {
+	if !c.Args().Present() {
+		log.Fatal("dump not specified")
+	}
+
+	m, err := backup.OpenManifest(backup.ManifestPath(c.Args().First()))
+	check.Fatalf(err, "open manifest: %v", err)
+
+	var w bytes.Buffer
+
+	tw := tabwriter.NewWriter(&w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "format:\t%d\n", m.Format)
+	fmt.Fprintf(tw, "esctl:\t%s\n", m.Esctl)
+	fmt.Fprintf(tw, "created:\t%s\n", m.Created.Format(time.RFC3339))
+	if m.Completed != nil {
+		fmt.Fprintf(tw, "completed:\t%s\n", m.Completed.Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(tw, "completed:\tno\n")
+	}
+	fmt.Fprintf(tw, "context:\t%s\n", m.Source.Context)
+	fmt.Fprintf(tw, "cluster:\t%s (%s)\n", m.Source.Cluster, m.Source.UUID)
+	fmt.Fprintf(tw, "elasticsearch:\t%s\n", m.Source.Version)
+	if len(m.Query) > 0 {
+		var query bytes.Buffer
+		_ = json.Compact(&query, m.Query)
+		fmt.Fprintf(tw, "query:\t%s\n", query.String())
+	}
+	if m.Transform != nil {
+		fmt.Fprintf(tw, "transform:\t%s\n", m.Transform)
+	}
+	if m.Compression != "" {
+		fmt.Fprintf(tw, "compression:\t%s\n", m.Compression)
+	}
+	fmt.Fprintf(tw, "docs:\t%d\n", m.Docs)
+	for _, f := range m.Files {
+		fmt.Fprintf(tw, "file:\t%s %d bytes sha256:%s\n", f.Name, f.Size, f.SHA256)
+	}
+	_ = tw.Flush()
+
+	_, _ = w.WriteTo(os.Stdout)
+
+	if err := m.Validate(); err != nil {
+		log.Printf("%v", err)
+	}
+
+	names := make([]string, 0, len(m.Indices))
+	for name := range m.Indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := table.New("index", "docs", "aliases")
+	for _, name := range names {
+		ind := m.Indices[name]
+
+		aliases := make([]string, 0, len(ind.Aliases))
+		for a := range ind.Aliases {
+			aliases = append(aliases, a)
+		}
+		sort.Strings(aliases)
+
+		t.Append(
+			[]string{
+				name,
+				fmt.Sprintf("%v", ind.Docs),
+				strings.Join(aliases, ","),
+			},
+		)
+	}
+	t.Render()
+}
@@ -0,0 +1,303 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/backup"
+	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/pkg/check"
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/diff"
+	"github.com/unqnown/esctl/pkg/table"
+	"github.com/urfave/cli"
+	"github.com/vbauerster/mpb"
+)
+
+// batch is an amount of sampled documents fetched per request.
+const batch = 1000
+
+var Command = cli.Command{
+	Name:                   "verify",
+	Usage:                  "Compares dump with indices of the current context.",
+	Description:            `Recounts documents of every dumped index and compares them with cluster. Cluster documents are counted under the query dump was taken with unless --all specified. With --sample random documents are fetched from cluster and compared field by field. Exits with error on any drift.`,
+	ArgsUsage:              "path/to/dump.json|path/to/chunked/dump [--sample 100] [--all]",
+	Action:                 ctl.NewAction(verify),
+	UseShortOptionHandling: true,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "sample, s",
+			Usage: "Compare `N` random documents with their cluster copies",
+		},
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "Count all cluster documents ignoring dump query",
+		},
+	},
+}
+
+// entry is a dumped document with raw body.
+type entry struct {
+	backup.Document
+
+	Body json.RawMessage
+}
+
+func verify(_ app.Config, conn *client.Client, c *cli.Context) error {
+	if !c.Args().Present() {
+		log.Fatal("dump not specified")
+	}
+
+	m, files, err := backup.Open(c.Args().First())
+	check.Fatalf(err, "open dump: %v", err)
+
+	var query elastic.Query
+	if m != nil {
+		err = m.Validate()
+		check.Fatal(err)
+		if len(m.Query) > 0 && !c.Bool("all") {
+			query = elastic.NewRawStringQuery(string(m.Query))
+		}
+	} else {
+		log.Printf("dump has no manifest, all cluster documents are counted")
+		m = &backup.Manifest{}
+	}
+
+	var size int64
+	for _, file := range files {
+		s, err := os.Stat(file)
+		check.Fatalf(err, "open dump file: %v", err)
+		size += s.Size()
+	}
+
+	rc := recount{
+		n:      c.Int("sample"),
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		counts: make(map[string]int64),
+	}
+
+	reading, wait := bar.Percent(size, "reading")
+
+	fetch := elastic.NewFetchSourceContext(true)
+	if m.Filter != nil {
+		// dumped documents are compared with the same fields of their cluster copies.
+		fetch.Include(m.Filter.Includes()...).Exclude(m.Filter.Exclude...)
+	}
+
+	sums := make([]string, len(files))
+	for i, file := range files {
+		sums[i], err = rc.read(file, reading)
+		check.Fatalf(err, "read dump %q: %v", file, err)
+	}
+
+	reading.SetTotal(size, true)
+	wait()
+
+	counts, sample := rc.counts, rc.sample
+
+	drift := 0
+
+	for i, file := range files {
+		if expected, ok := m.File(file); ok && expected.SHA256 != "" && expected.SHA256 != sums[i] {
+			log.Printf("%s checksum mismatch: expected %s, got %s", file, expected.SHA256, sums[i])
+			drift++
+		}
+	}
+
+	for name := range m.Indices {
+		if _, ok := counts[name]; !ok {
+			counts[name] = 0
+		}
+	}
+
+	if m.Partial() {
+		log.Printf("dump is limited or sampled, document counts are not compared")
+	} else {
+		drift += recountDrift(conn, counts, query)
+	}
+
+	if len(sample) > 0 && m.Transform != nil {
+		log.Printf("dump is transformed with %s, documents are not compared", m.Transform)
+	} else if len(sample) > 0 {
+		differ, err := compare(conn, sample, fetch)
+		check.Fatalf(err, "compare sample: %v", err)
+
+		log.Printf("%d of %d sampled documents differ", differ, len(sample))
+		drift += differ
+	}
+
+	if drift > 0 {
+		log.Fatalf("dump drifted from cluster")
+	}
+
+	log.Printf("dump matches cluster")
+
+	return nil
+}
+
+// recount counts dumped documents per index and picks random sample of them.
+type recount struct {
+	n      int
+	rnd    *rand.Rand
+	seen   int64
+	counts map[string]int64
+	sample []entry
+}
+
+// read recounts documents of the dump file and returns its checksum.
+func (rc *recount) read(file string, b *mpb.Bar) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sum, _, err := backup.Read(b.ProxyReader(f), rc.add)
+
+	return sum, err
+}
+
+// add counts record and keeps it in sample with equal probability.
+func (rc *recount) add(rec backup.Record) error {
+	doc, err := rec.Decode()
+	if err != nil {
+		return err
+	}
+	e := entry{Document: doc}
+	if rc.n > 0 {
+		if e.Body, err = json.Marshal(doc.Body); err != nil {
+			return err
+		}
+	}
+	rc.counts[e.Index]++
+
+	// reservoir sampling keeps every document with equal probability.
+	switch {
+	case len(rc.sample) < rc.n:
+		rc.sample = append(rc.sample, e)
+	case rc.n > 0:
+		if i := rc.rnd.Int63n(rc.seen + 1); i < int64(rc.n) {
+			rc.sample[i] = e
+		}
+	}
+	rc.seen++
+
+	return nil
+}
+
+// recountDrift prints dumped and cluster document counts per index
+// and returns amount of indices which differ.
+func recountDrift(conn *client.Client, counts map[string]int64, query elastic.Query) int {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	drift := 0
+
+	t := table.New("index", "dump", "cluster", "drift")
+	for _, name := range names {
+		count, err := countDocs(conn, name, query)
+		switch {
+		case elastic.IsNotFound(err):
+			t.Append([]string{name, fmt.Sprint(counts[name]), "missing", "-"})
+			drift++
+
+			continue
+		case err != nil:
+			check.Fatalf(err, "count %q: %v", name, err)
+		}
+
+		d := count - counts[name]
+		if d != 0 {
+			drift++
+		}
+		t.Append([]string{name, fmt.Sprint(counts[name]), fmt.Sprint(count), fmt.Sprintf("%+d", d)})
+	}
+	t.Render()
+
+	return drift
+}
+
+func countDocs(conn *client.Client, index string, query elastic.Query) (int64, error) {
+	if query != nil {
+		return conn.Count(index).Query(query).Do(context.Background())
+	}
+
+	rows, err := conn.CatCount().Index(index).Do(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, row := range rows {
+		count += int64(row.Count)
+	}
+
+	return count, nil
+}
+
+// compare fetches sampled documents from cluster and prints their differences.
+// It returns amount of documents which differ or are missing.
+func compare(conn *client.Client, sample []entry, fetch *elastic.FetchSourceContext) (int, error) {
+	differ := 0
+
+	for start := 0; start < len(sample); start += batch {
+		end := start + batch
+		if end > len(sample) {
+			end = len(sample)
+		}
+
+		mget := conn.Mget()
+		for _, e := range sample[start:end] {
+			item := elastic.NewMultiGetItem().Index(e.Index).Id(e.ID).FetchSource(fetch)
+			if e.Routing != "" {
+				item.Routing(e.Routing)
+			}
+			mget.Add(item)
+		}
+
+		rsp, err := mget.Do(context.Background())
+		if err != nil {
+			return differ, err
+		}
+
+		for i, doc := range rsp.Docs {
+			e := sample[start+i]
+			if doc.Error != nil || !doc.Found {
+				log.Printf("%s/%s: missing in cluster", e.Index, e.ID)
+				differ++
+
+				continue
+			}
+
+			changes, err := diff.JSON(e.Body, doc.Source)
+			if err != nil {
+				return differ, fmt.Errorf("%s/%s: %w", e.Index, e.ID, err)
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			differ++
+
+			lines := make([]string, len(changes))
+			for j, ch := range changes {
+				lines[j] = "\t" + ch.String()
+			}
+			log.Printf("%s/%s differs (dump != cluster):\n%s", e.Index, e.ID, strings.Join(lines, "\n"))
+		}
+	}
+
+	return differ, nil
+}
@@ -0,0 +1,149 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/unqnown/esctl/pkg/diff"
+)
+
+// sample describes documents dry run is performed on.
+type sample struct {
+	index string
+	query elastic.Query
+	fetch *elastic.FetchSourceContext
+	n     int
+}
+
+// dryRun reindexes sample documents into temporary index with the same request
+// and prints how documents are changed. Temporary index is deleted afterwards.
+func dryRun(sconn, conn *client.Client, body map[string]interface{}, smp sample) error {
+	ctx := context.Background()
+
+	if script, ok := body["script"].(map[string]interface{}); ok && strings.Contains(fmt.Sprint(script["source"]), "ctx._index") {
+		// documents would be written outside of temporary index.
+		return errors.New("script changing ctx._index can't be tried out")
+	}
+
+	search := sconn.Search(strings.Split(smp.index, ",")...).Size(smp.n)
+	if smp.query != nil {
+		search.Query(smp.query)
+	}
+	if smp.fetch != nil {
+		search.FetchSourceContext(smp.fetch)
+	}
+	rsp, err := search.Do(ctx)
+	if err != nil {
+		return fmt.Errorf("sample documents: %w", err)
+	}
+	if rsp.Hits == nil || len(rsp.Hits.Hits) == 0 {
+		log.Printf("no documents to try reindex on")
+
+		return nil
+	}
+	hits := rsp.Hits.Hits
+
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.Id
+	}
+
+	tmp := fmt.Sprintf("esctl-dry-run-%d", time.Now().UnixNano())
+
+	source := clone(body["source"])
+	filter := []interface{}{map[string]interface{}{"ids": map[string]interface{}{"values": ids}}}
+	if q, ok := source["query"]; ok {
+		filter = append(filter, q)
+	}
+	source["query"] = map[string]interface{}{"bool": map[string]interface{}{"filter": filter}}
+
+	dest := clone(body["dest"])
+	dest["index"] = tmp
+	// sampled documents are new to temporary index anyway.
+	delete(dest, "op_type")
+
+	req := map[string]interface{}{
+		"source":    source,
+		"dest":      dest,
+		"conflicts": "proceed",
+	}
+	if script, ok := body["script"]; ok {
+		req["script"] = script
+	}
+
+	defer func() {
+		if _, err := conn.DeleteIndex(tmp).Do(ctx); err != nil && !elastic.IsNotFound(err) {
+			log.Printf("delete temporary index %q: %v", tmp, err)
+		}
+	}()
+
+	res, err := conn.Reindex().Body(req).Refresh("true").Do(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range res.Failures {
+		log.Printf("%s/%s: failed with status %d", f.Index, f.Id, f.Status)
+	}
+
+	mget := conn.Mget()
+	for _, h := range hits {
+		item := elastic.NewMultiGetItem().Index(tmp).Id(h.Id)
+		if h.Routing != "" {
+			item.Routing(h.Routing)
+		}
+		mget.Add(item)
+	}
+	got, err := mget.Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for i, doc := range got.Docs {
+		h := hits[i]
+		if doc.Error != nil || !doc.Found {
+			log.Printf("%s/%s: not reindexed", h.Index, h.Id)
+			changed++
+
+			continue
+		}
+
+		changes, err := diff.JSON(h.Source, doc.Source)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", h.Index, h.Id, err)
+		}
+		if len(changes) == 0 {
+			log.Printf("%s/%s: unchanged", h.Index, h.Id)
+
+			continue
+		}
+		changed++
+
+		lines := make([]string, len(changes))
+		for j, ch := range changes {
+			lines[j] = "\t" + ch.String()
+		}
+		log.Printf("%s/%s (before != after):\n%s", h.Index, h.Id, strings.Join(lines, "\n"))
+	}
+
+	log.Printf("%d of %d sampled documents changed", changed, len(hits))
+
+	return nil
+}
+
+// clone makes shallow copy of request part.
+func clone(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
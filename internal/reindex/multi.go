@@ -0,0 +1,148 @@
+package reindex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/unqnown/esctl/internal/task"
+	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/unqnown/esctl/pkg/indexmap"
+	"github.com/unqnown/esctl/pkg/table"
+)
+
+// family describes reindex of every source index into its own destination.
+type family struct {
+	src, dst string
+	parallel int
+	detach   bool
+}
+
+// job is a reindex of a single index of the family.
+type job struct {
+	from, to string
+	task     client.Task
+	took     time.Duration
+	err      error
+}
+
+// many reindexes source indices into destinations named by pattern,
+// at most parallel indices at once, and prints summary of every reindex.
+func many(sconn, conn *client.Client, body map[string]interface{}, submit func(map[string]interface{}) (string, error), f family) error {
+	rule, err := indexmap.Glob(f.src, f.dst)
+	if err != nil {
+		return err
+	}
+
+	rows, err := sconn.CatIndices().Index(f.src).Columns("index").Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("list source indices: %w", err)
+	}
+
+	jobs := make([]*job, 0, len(rows))
+	for _, row := range rows {
+		to, ok := rule.Map(row.Index)
+		if !ok {
+			continue
+		}
+		jobs = append(jobs, &job{from: row.Index, to: to})
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no indices match %q", f.src)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].from < jobs[j].from })
+
+	// request of every index differs in source and destination only.
+	request := func(j *job) map[string]interface{} {
+		req := clone(body)
+		source, dest := clone(body["source"]), clone(body["dest"])
+		source["index"], dest["index"] = j.from, j.to
+		req["source"], req["dest"] = source, dest
+
+		return req
+	}
+
+	if f.detach {
+		for _, j := range jobs {
+			id, err := submit(request(j))
+			if err != nil {
+				return fmt.Errorf("start reindex of %q: %w", j.from, err)
+			}
+			log.Printf("reindex task %s of %q into %q started: esctl task watch %s", id, j.from, j.to, id)
+		}
+
+		return nil
+	}
+
+	progress := bar.New()
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, f.parallel)
+	)
+	for _, j := range jobs {
+		b := progress.Docs(0, fmt.Sprintf("%s -> %s", j.from, j.to))
+
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			started := time.Now()
+			defer func() { j.took = time.Since(started) }()
+
+			id, err := submit(request(j))
+			if err != nil {
+				j.err = err
+				b.SetTotal(0, true)
+
+				return
+			}
+			j.task, j.err = task.Track(conn, id, b, nil)
+		}(j)
+	}
+	wg.Wait()
+
+	progress.Wait()
+
+	failed := 0
+
+	t := table.New("source", "destination", "task", "state", "total", "created", "updated", "conflicts", "took")
+	for _, j := range jobs {
+		st := task.Status(j.task)
+		id, state := j.task.ID, task.State(j.task)
+		if j.err != nil {
+			state = j.err.Error()
+		}
+		if id == "" {
+			id = "-"
+		}
+		if j.err != nil || !task.Report(j.task) {
+			failed++
+		}
+		t.Append([]string{
+			j.from,
+			j.to,
+			id,
+			state,
+			fmt.Sprint(st.Total),
+			fmt.Sprint(st.Created),
+			fmt.Sprint(st.Updated),
+			fmt.Sprint(st.VersionConflicts),
+			j.took.Round(time.Second).String(),
+		})
+	}
+	t.Render()
+
+	if failed > 0 {
+		log.Fatalf("reindex of %d of %d indices failed", failed, len(jobs))
+	}
+
+	return nil
+}
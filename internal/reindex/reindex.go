@@ -3,27 +3,32 @@ package reindex
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/olivere/elastic/v7"
 	"github.com/unqnown/esctl/internal/app"
-	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/internal/task"
 	"github.com/unqnown/esctl/pkg/check"
 	"github.com/unqnown/esctl/pkg/client"
 	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/query"
+	"github.com/unqnown/esctl/pkg/transform"
 	"github.com/urfave/cli"
 )
 
 var Command = cli.Command{
 	Name:                   "reindex",
 	Usage:                  "Copies documents from one index to another.",
-	Description:            `For more information: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-reindex.html`,
-	ArgsUsage:              "--src source --dst destination [--remote old]",
+	Description:            `Destination with wildcards reindexes every matching source index separately, e.g. --src 'logs-2024.*' --dst 'logs-v2-2024.*'. For more information: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-reindex.html`,
+	ArgsUsage:              "--src source --dst destination [--parallel 2] [--remote old] [--slices auto|N] [--requests-per-second N] [--max-docs N] [--query query.json] [--op-type create] [--conflicts abort|proceed] [--include 'pattern*'] [--exclude 'pattern*'] [--pipeline name] [--script script.painless] [--param k=v] [--dry-run N] [--detach]",
 	Category:               "Intermediate",
 	Action:                 ctl.NewAction(reindex),
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:     "source, src, s",
 			Required: true,
@@ -32,12 +37,17 @@ var Command = cli.Command{
 		cli.StringFlag{
 			Name:     "destination, dst, d",
 			Required: true,
-			Usage:    "Destination `index` name in current context",
+			Usage:    "Destination `index` name in current context. Wildcards and $1 refer to wildcards of source",
+		},
+		cli.IntFlag{
+			Name:  "parallel, P",
+			Usage: "Number of indices reindexed concurrently if destination is a pattern",
+			Value: 2,
 		},
 		cli.StringFlag{
 			Name:     "remote, r",
 			Required: false,
-			Usage:    "Remote cluster `context`",
+			Usage:    "Remote cluster `context`. Its first reachable server is used, with headers and api key of the context",
 		},
 		cli.DurationFlag{
 			Name:     "connection_timeout",
@@ -59,7 +69,57 @@ var Command = cli.Command{
 				"which defaults to a maximum size of 100 MB.",
 			Value: 1000,
 		},
-	},
+		cli.StringFlag{
+			Name:  "slices",
+			Usage: "Number of `SLICES` reindex is divided into, auto lets cluster choose",
+			Value: "1",
+		},
+		cli.IntFlag{
+			Name:  "requests-per-second, rps",
+			Usage: "Throttle reindex to `N` requests per second, -1 disables throttling. Can be changed later with esctl task rethrottle",
+		},
+		cli.Int64Flag{
+			Name:  "max-docs",
+			Usage: "Reindex at most `N` documents of every index",
+		},
+		cli.StringFlag{
+			Name:  "op-type",
+			Usage: "Destination operation `TYPE`: index or create. Create skips existing documents",
+		},
+		cli.StringFlag{
+			Name:  "conflicts",
+			Usage: "Version conflicts `HANDLING`: abort or proceed",
+			Value: "proceed",
+		},
+		cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "Reindex only source fields matching `PATTERN`",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Do not reindex source fields matching `PATTERN`",
+		},
+		cli.StringFlag{
+			Name:  "pipeline",
+			Usage: "Destination ingest `PIPELINE`",
+		},
+		cli.StringFlag{
+			Name:  "script",
+			Usage: "Transform documents with painless script `FILE`",
+		},
+		cli.StringSliceFlag{
+			Name:  "param",
+			Usage: "Script parameter: `key=value`. Value is parsed as json, falls back to string",
+		},
+		cli.IntFlag{
+			Name:  "dry-run",
+			Usage: "Reindex `N` sample documents into temporary index and print their diffs instead of reindex",
+		},
+		cli.BoolFlag{
+			Name:  "detach",
+			Usage: "Start reindex task and exit without waiting for it",
+		},
+	}, query.Flags...),
 }
 
 func reindex(conf app.Config, conn *client.Client, c *cli.Context) error {
@@ -70,94 +130,170 @@ func reindex(conf app.Config, conn *client.Client, c *cli.Context) error {
 		Request(elastic.NewSearchRequest().Size(c.Int("size"))).
 		Index(sind)
 
-	var total int64
+	q, err := query.Parse(conf, c)
+	check.Fatalf(err, "parse query: %v", err)
+	if q != nil {
+		src.Query(q)
+	}
+	if fsc := fetch(c); fsc != nil {
+		src.FetchSourceContext(fsc)
+	}
 
-	if remote := c.String("remote"); remote != "" {
-		rctx, ok := conf.Contexts[remote]
-		if !ok {
-			log.Fatalf("remote cluster is not configured, please add it to esctl config.")
+	dst := elastic.NewReindexDestination().Index(dind)
+	switch op := c.String("op-type"); op {
+	case "", "index", "create":
+		dst.OpType(op)
+	default:
+		log.Fatalf("unsupported op type %q", op)
+	}
+	if pipeline := c.String("pipeline"); pipeline != "" {
+		dst.Pipeline(pipeline)
+	}
+
+	switch conflicts := c.String("conflicts"); conflicts {
+	case "abort", "proceed":
+	default:
+		log.Fatalf("unsupported conflicts handling %q", conflicts)
+	}
+
+	var script *elastic.Script
+	if path := c.String("script"); path != "" {
+		script, err = openScript(path, c.StringSlice("param"))
+		check.Fatalf(err, "open script: %v", err)
+	}
+
+	var slices interface{} = "auto"
+	if s := c.String("slices"); s != "auto" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			log.Fatalf("malformed slices %q: expected auto or positive number", s)
 		}
+		slices = n
+	}
 
-		rcluster, ok := conf.Clusters[rctx.Cluster]
-		if !ok {
-			log.Fatalf("remote cluster is not configured, please add it to esctl config.")
+	body, err := request(src, dst, script, c.String("conflicts"), c.Int64("max-docs"))
+	check.Fatalf(err, "build reindex request: %v", err)
+
+	sconn := conn
+	if name := c.String("remote"); name != "" {
+		var ri map[string]interface{}
+		sconn, ri, err = dial(conf, remote{
+			name:    name,
+			connect: c.Duration("connection_timeout"),
+			socket:  c.Duration("socket_timeout"),
+		})
+		check.Fatalf(err, "connect to %s cluster: %v", name, err)
+
+		source := clone(body["source"])
+		source["remote"] = ri
+		body["source"] = source
+	}
+
+	if n := c.Int("dry-run"); n > 0 {
+		err = dryRun(sconn, conn, body, sample{index: sind, query: q, fetch: fetch(c), n: n})
+		check.Fatalf(err, "dry run: %v", err)
+
+		return nil
+	}
+
+	submit := func(body map[string]interface{}) (string, error) {
+		svc := conn.Reindex().Body(body).Slices(slices)
+		if c.IsSet("requests-per-second") {
+			svc.RequestsPerSecond(c.Int("requests-per-second"))
 		}
 
-		var ruser app.User
-		if rctx.User != nil {
-			ruser = conf.Users[*rctx.User]
+		rsp, err := svc.DoAsync(context.Background())
+		if err != nil {
+			return "", err
 		}
 
-		src.RemoteInfo(
-			elastic.NewReindexRemoteInfo().
-				Host(rcluster.Servers[0]).
-				Username(ruser.Name).
-				Password(ruser.Password).
-				ConnectTimeout(fmt.Sprintf("%0.fs", c.Duration("connection_timeout").Seconds())).
-				SocketTimeout(fmt.Sprintf("%0.fs", c.Duration("socket_timeout").Seconds())),
-		)
+		return rsp.TaskId, nil
+	}
 
-		rconn, err := client.New(rcluster, ruser)
-		check.Fatalf(err, "connect to %s cluster: %v", remote, err)
+	if strings.ContainsAny(dind, "*$") {
+		if c.Int("parallel") < 1 {
+			log.Fatal("parallel must be positive")
+		}
+
+		return many(sconn, conn, body, submit, family{
+			src:      sind,
+			dst:      dind,
+			parallel: c.Int("parallel"),
+			detach:   c.Bool("detach"),
+		})
+	}
 
-		cat, err := rconn.CatCount().Index(sind).Do(context.Background())
-		check.Fatalf(err, "cat count: %v", err)
+	id, err := submit(body)
+	check.Fatalf(err, "start reindex: %v", err)
 
-		total = int64(cat[0].Count)
-	} else {
-		cat, err := conn.CatCount().Index(sind).Do(context.Background())
-		check.Fatalf(err, "cat count: %v", err)
+	if c.Bool("detach") {
+		log.Printf("reindex task %s started: esctl task watch %s", id, id)
 
-		total = int64(cat[0].Count)
+		return nil
 	}
 
-	reindexing, wait := bar.Docs(total, "reindexing")
-
-	started := time.Now()
+	t := task.Follow(conn, id, "reindexing")
+	if !task.Report(t) {
+		log.Fatal("reindex failed")
+	}
 
-	done := make(chan struct{})
+	return nil
+}
 
-	exists, err := conn.CatCount().Index(dind).Do(context.Background())
-	check.Fatal(err)
+// request builds reindex request body.
+// It is built by hand since reindex service knows nothing about max_docs.
+func request(src *elastic.ReindexSource, dst *elastic.ReindexDestination, script *elastic.Script, conflicts string, max int64) (map[string]interface{}, error) {
+	source, err := src.Source()
+	if err != nil {
+		return nil, err
+	}
+	dest, err := dst.Source()
+	if err != nil {
+		return nil, err
+	}
 
-	go func() {
-		var prev int
-		if len(exists) > 0 {
-			prev = exists[0].Count
-		}
-	watch:
-		for {
-			// TODO: index out of range
-			select {
-			case <-time.NewTicker(time.Second).C:
-				reindexed, _ := conn.CatCount().Index(dind).Do(context.Background())
-				if len(reindexed) == 0 {
-					continue
-				}
-				reindexing.IncrBy(reindexed[0].Count-prev, time.Since(started))
-				prev = reindexed[0].Count
-			case <-done:
-				reindexed, _ := conn.CatCount().Index(dind).Do(context.Background())
-				if len(reindexed) == 0 {
-					reindexing.SetTotal(total, true)
-				} else {
-					reindexing.SetTotal(int64(reindexed[0].Count), true)
-				}
-				break watch
-			}
+	body := map[string]interface{}{
+		"source":    source,
+		"dest":      dest,
+		"conflicts": conflicts,
+	}
+	if max > 0 {
+		body["max_docs"] = max
+	}
+	if script != nil {
+		if body["script"], err = script.Source(); err != nil {
+			return nil, err
 		}
-	}()
+	}
 
-	_, err = conn.Reindex().
-		Source(src).
-		DestinationIndex(dind).
-		ProceedOnVersionConflict().
-		Do(context.Background())
-	check.Fatal(err)
+	return body, nil
+}
 
-	close(done)
+// fetch returns source filter of reindexed documents, nil if documents are reindexed as is.
+func fetch(c *cli.Context) *elastic.FetchSourceContext {
+	include, exclude := c.StringSlice("include"), c.StringSlice("exclude")
+	if len(include)+len(exclude) == 0 {
+		return nil
+	}
 
-	wait()
+	return elastic.NewFetchSourceContext(true).Include(include...).Exclude(exclude...)
+}
 
-	return nil
+// openScript reads painless script with its parameters.
+func openScript(path string, params []string) (*elastic.Script, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script := elastic.NewScript(string(data)).Lang("painless")
+	for _, p := range params {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("malformed param %q: expected key=value", p)
+		}
+		script.Param(kv[0], transform.Value(kv[1]))
+	}
+
+	return script, nil
 }
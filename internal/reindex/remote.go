@@ -0,0 +1,73 @@
+package reindex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/client"
+)
+
+// remote describes source cluster of remote reindex.
+type remote struct {
+	name            string
+	connect, socket time.Duration
+}
+
+// dial connects to the first reachable server of the remote context
+// and returns remote info of reindex request pointing to it.
+func dial(conf app.Config, r remote) (*client.Client, map[string]interface{}, error) {
+	rctx, ok := conf.Contexts[r.name]
+	if !ok {
+		return nil, nil, fmt.Errorf("remote context %q is not configured, please add it to esctl config", r.name)
+	}
+	rcluster, ok := conf.Clusters[rctx.Cluster]
+	if !ok {
+		return nil, nil, fmt.Errorf("remote cluster %q is not configured, please add it to esctl config", rctx.Cluster)
+	}
+	var ruser app.User
+	if rctx.User != nil {
+		if ruser, ok = conf.Users[*rctx.User]; !ok {
+			return nil, nil, fmt.Errorf("remote user %q is not configured, please add it to esctl config", *rctx.User)
+		}
+	}
+
+	server, info, err := client.Reachable(context.Background(), rcluster, ruser)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("reindex from %s cluster %s (%s)", r.name, info.Name, server)
+
+	// destination cluster connects to the remote itself, so it must trust remote certificates:
+	// TLS of the context can't be passed with the request.
+	if rcluster.TLS != nil {
+		log.Printf("destination cluster must trust %s certificates, see reindex.ssl.* settings", r.name)
+	}
+
+	// failover across servers of the remote is up to esctl: remote reindex accepts a single host.
+	rcluster.Servers = []string{server}
+	sconn, err := client.New(rcluster, ruser)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ri := map[string]interface{}{
+		"host":            server,
+		"connect_timeout": fmt.Sprintf("%0.fs", r.connect.Seconds()),
+		"socket_timeout":  fmt.Sprintf("%0.fs", r.socket.Seconds()),
+	}
+	if ruser.APIKey == "" && ruser.Name != "" {
+		ri["username"], ri["password"] = ruser.Name, ruser.Password
+	}
+	if headers := client.Headers(rcluster, ruser); len(headers) > 0 {
+		h := make(map[string]string, len(headers))
+		for k := range headers {
+			h[k] = headers.Get(k)
+		}
+		ri["headers"] = h
+	}
+
+	return sconn, ri, nil
+}
@@ -1,10 +1,17 @@
 package restore
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
+	"fmt"
 	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/unqnown/esctl/internal/app"
 	"github.com/unqnown/esctl/pkg/backup"
@@ -12,6 +19,8 @@ import (
 	"github.com/unqnown/esctl/pkg/check"
 	"github.com/unqnown/esctl/pkg/client"
 	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/indexmap"
+	"github.com/unqnown/esctl/pkg/transform"
 	"github.com/urfave/cli"
 	"github.com/vbauerster/mpb"
 )
@@ -20,69 +29,340 @@ var Command = cli.Command{
 	Name:                   "restore",
 	Aliases:                []string{"import"},
 	Usage:                  "Imports content to index.",
-	Description:            `If index not specified documents will be restored to their indices.`,
-	ArgsUsage:              "[index] --dump path/to/dump.json [--lpr 1000]",
+	Description:            `If index not specified documents will be restored to their indices. Missing indices are created from dump manifest. Documents keep their routing. Compressed dumps and _bulk request bodies are detected automatically. Several dumps and parts of chunked dump are read concurrently.`,
+	ArgsUsage:              "[index] --dump path/to/dump.json [--dump 'path/to/dumps/*'] [--index-map pattern=replacement]",
 	Category:               "Intermediate",
 	Action:                 ctl.NewAction(restore),
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append(append([]cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "dump, d",
+			Usage: "Dump `FILE`, chunked dump directory, manifest or - for stdin. Repeatable, wildcards are expanded",
+		},
+		cli.StringFlag{
+			Name:  "manifest",
+			Usage: "Manifest `FILE` of dump read from stdin",
+		},
 		cli.StringFlag{
-			Name:     "dump, d",
-			Required: true,
-			Usage:    "Dump `FILE`",
+			Name:  "version-type",
+			Usage: "Replay dumped document versions with `TYPE`: external or external_gte",
+		},
+		cli.StringFlag{
+			Name:  "dead-letter",
+			Usage: "Write documents rejected by cluster to `FILE`. It can be restored as a dump",
+		},
+		cli.StringSliceFlag{
+			Name:  "index-map, m",
+			Usage: "Restore indices under new names: `pattern=replacement`, e.g. logs-2023.*=archive-$1 or /^(.+)$/=$1-copy",
 		},
-	},
+		cli.IntFlag{
+			Name:  "parallel, P",
+			Usage: "Number of dump files read concurrently",
+			Value: 4,
+		},
+		cli.IntFlag{
+			Name:  "decoders",
+			Usage: "Number of goroutines decoding documents",
+			Value: runtime.NumCPU(),
+		},
+		cli.BoolFlag{
+			Name:  "no-create",
+			Usage: "Do not create indices from dump manifest",
+		},
+	}, transform.Flags...), ctl.BulkFlags...),
 }
 
-func restore(_ app.Config, conn *client.Client, c *cli.Context) error {
-	processor, err := conn.Bulk()
+func restore(conf app.Config, conn *client.Client, c *cli.Context) error {
+	settings, err := ctl.Bulk(conf, c)
+	check.Fatal(err)
+
+	processor, err := conn.Bulk(settings)
 	check.Fatalf(err, "start bulk processor: %v", err)
 
-	r, restoring, wait, err := factory(c.String("dump"))
-	check.Fatalf(err, "open dump file: %v", err)
-	defer r.Close()
+	closeDL, err := ctl.DeadLetter(processor, c)
+	check.Fatalf(err, "create dead letter file: %v", err)
+	defer closeDL()
 
-	dec := json.NewDecoder(r)
+	stop, err := ctl.Limit(processor, c)
+	check.Fatalf(err, "limit rate: %v", err)
+	defer stop()
 
-	index, present := c.Args().First(), c.Args().Present()
+	switch vt := c.String("version-type"); vt {
+	case "", "external", "external_gte":
+		processor.VersionType = vt
+	default:
+		log.Fatalf("unsupported version type %q", vt)
+	}
 
-	for {
-		var doc backup.Document
+	pipeline, err := transform.Parse(c)
+	check.Fatalf(err, "parse transformation: %v", err)
 
-		if err := dec.Decode(&doc); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
+	mapping, err := indexmap.ParseAll(c.StringSlice("index-map")...)
+	check.Fatal(err)
+
+	rename := mapping.Map
+	if index := c.Args().First(); c.Args().Present() {
+		if len(mapping) > 0 {
+			log.Fatal("index and --index-map are mutually exclusive")
+		}
+		rename = func(string) string { return index }
+	}
+
+	if len(c.StringSlice("dump")) == 0 {
+		log.Fatal("dump not specified")
+	}
+
+	manifests, files, err := expand(c.StringSlice("dump")...)
+	check.Fatalf(err, "open dump: %v", err)
+
+	if path := c.String("manifest"); path != "" {
+		m, err := backup.OpenManifest(path)
+		check.Fatalf(err, "open manifest: %v", err)
+
+		manifests = append(manifests, m)
+		for i := range files {
+			if files[i].path == stdin && len(m.Files) == 1 {
+				files[i].expected = m.Files[0]
 			}
-			check.Fatalf(err, "read dump: %v", err)
 		}
+	}
+
+	for _, m := range manifests {
+		err = m.Validate()
+		check.Fatal(err)
 
-		if present {
-			doc.Index = index
+		describe(m)
+
+		if !c.Bool("no-create") {
+			// indices missing in the cluster are created from manifest under their new names.
+			err = conn.CreateIndices(context.Background(), m.Indices, rename)
+			check.Fatalf(err, "create indices: %v", err)
+		}
+	}
+
+	parallel, decoders := c.Int("parallel"), c.Int("decoders")
+	if parallel < 1 || decoders < 1 {
+		log.Fatal("parallel and decoders must be positive")
+	}
+
+	var (
+		size   int64
+		stream bool
+	)
+	for i, f := range files {
+		if f.path == stdin {
+			stream = true
+
+			continue
 		}
+		s, err := os.Stat(f.path)
+		check.Fatalf(err, "open dump file: %v", err)
+		files[i].size = s.Size()
+		size += s.Size()
+	}
+
+	extra := ctl.Decorators(processor, settings, c)
+
+	progress := bar.New()
+	// bars track compressed bytes read.
+	var restoring *mpb.Bar
+	if stream {
+		restoring = progress.Stream("restoring", extra...)
+	} else {
+		restoring = progress.Percent(size, "restoring", extra...)
+	}
+
+	records := make(chan record, 100*decoders)
+
+	var decoding sync.WaitGroup
+	for i := 0; i < decoders; i++ {
+		decoding.Add(1)
+		go func() {
+			defer decoding.Done()
+
+			for r := range records {
+				doc, err := r.Decode()
+				check.Fatalf(err, "read dump %q: %v", r.file, err)
+
+				err = pipeline.Apply(&doc)
+				check.Fatalf(err, "transform %q: %v", doc.ID, err)
 
-		processor.Save(doc)
+				doc.Index = rename(doc.Index)
+
+				processor.Save(doc)
+			}
+		}()
 	}
 
+	var (
+		reading    sync.WaitGroup
+		sem        = make(chan struct{}, parallel)
+		mu         sync.Mutex
+		mismatches []string
+		total      int64
+	)
+	for _, f := range files {
+		reading.Add(1)
+		sem <- struct{}{}
+		go func(f file) {
+			defer func() {
+				<-sem
+				reading.Done()
+			}()
+
+			bars := []*mpb.Bar{restoring}
+			if f.path != stdin {
+				b := progress.Transient(f.size, f.path)
+				defer b.SetTotal(f.size, true)
+
+				bars = append(bars, b)
+			}
+
+			sum, n := read(f.path, records, bars...)
+			atomic.AddInt64(&total, n)
+
+			if f.expected.SHA256 != "" && f.expected.SHA256 != sum {
+				mu.Lock()
+				mismatches = append(mismatches, fmt.Sprintf("%s: expected %s, got %s", f.path, f.expected.SHA256, sum))
+				mu.Unlock()
+			}
+		}(f)
+	}
+	reading.Wait()
+	close(records)
+	decoding.Wait()
+
 	err = processor.Close()
 	check.Fatalf(err, "flush save tasks: %v", err)
 
-	restoring.SetTotal(0, true)
+	restoring.SetTotal(total, true)
 
-	wait()
+	progress.Wait()
+
+	if len(mismatches) > 0 {
+		log.Fatalf("dump checksum mismatch:\n%s", strings.Join(mismatches, "\n"))
+	}
+
+	err = ctl.Rejected(processor, c)
+	check.Fatalf(err, "restore: %v", err)
 
 	return nil
 }
 
-func factory(file string) (io.ReadCloser, *mpb.Bar, func(), error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, nil, nil, err
+// describe prints dump origin.
+func describe(m backup.Manifest) {
+	if m.Format == 0 {
+		// manifest predates dump format versioning.
+		return
+	}
+	log.Printf("dump of %d documents from %q cluster %s (context %q, elasticsearch %s) taken at %s by esctl %s",
+		m.Docs, m.Source.Cluster, m.Source.UUID, m.Source.Context, m.Source.Version,
+		m.Created.Format(time.RFC3339), m.Esctl)
+	if m.Completed == nil {
+		log.Printf("dump is incomplete")
+	}
+	if m.Transform != nil {
+		log.Printf("dumped documents were transformed with %s", m.Transform)
+	}
+}
+
+// stdin refers to dump read from standard input.
+const stdin = "-"
+
+// file is a dump file to restore.
+type file struct {
+	path     string
+	size     int64
+	expected backup.File
+}
+
+// expand resolves dump patterns into manifests and files to restore.
+// Every pattern is either a dump file, a chunked dump directory or a manifest
+// and may contain wildcards.
+func expand(patterns ...string) (manifests []backup.Manifest, files []file, err error) {
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(matches) == 0 && pattern != stdin {
+			return nil, nil, fmt.Errorf("%q: %w", pattern, os.ErrNotExist)
+		}
+
+		if pattern == stdin {
+			matches = []string{stdin}
+		}
+
+		for _, match := range matches {
+			if strings.HasSuffix(match, ".checkpoint") || strings.HasSuffix(match, ".checkpoint.tmp") {
+				// checkpoint of incomplete dump.
+				continue
+			}
+
+			var (
+				m     *backup.Manifest
+				paths = []string{stdin}
+			)
+			if match != stdin {
+				if m, paths, err = backup.Open(match); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			added := false
+			for _, path := range paths {
+				path = filepath.Clean(path)
+				if seen[path] {
+					continue
+				}
+				seen[path], added = true, true
+
+				f := file{path: path}
+				if m != nil {
+					f.expected, _ = m.File(path)
+				}
+				files = append(files, f)
+			}
+			if m != nil && added {
+				manifests = append(manifests, *m)
+			}
+		}
+	}
+
+	return manifests, files, nil
+}
+
+// record is a raw dumped document.
+type record struct {
+	backup.Record
+
+	file string
+}
+
+// read sends raw documents of the dump file to decoders and returns its checksum and size.
+// Decoding of documents themselves is left to decoders, so reader only splits the stream.
+func read(path string, records chan<- record, bars ...*mpb.Bar) (string, int64) {
+	f := os.Stdin
+	if path != stdin {
+		var err error
+		f, err = os.Open(path)
+		check.Fatalf(err, "open dump file: %v", err)
 	}
-	s, err := f.Stat()
-	if err != nil {
-		return nil, nil, nil, err
+	defer f.Close()
+
+	var src io.Reader = f
+	for _, b := range bars {
+		src = b.ProxyReader(src)
 	}
-	b, wait := bar.Percent(s.Size(), "restoring")
 
-	return b.ProxyReader(f), b, wait, nil
+	sum, size, err := backup.Read(src, func(rec backup.Record) error {
+		records <- record{Record: rec, file: path}
+
+		return nil
+	})
+	check.Fatalf(err, "read dump %q: %v", path, err)
+
+	return sum, size
 }
@@ -0,0 +1,228 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/pkg/check"
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/table"
+	"github.com/urfave/cli"
+	"github.com/vbauerster/mpb"
+)
+
+var Command = cli.Command{
+	Name:                   "task",
+	Aliases:                []string{"tasks"},
+	Usage:                  "Manages long running tasks such as reindex.",
+	Description:            "For more information: https://www.elastic.co/guide/en/elasticsearch/reference/current/tasks.html",
+	Category:               "Advanced",
+	UseShortOptionHandling: true,
+	Subcommands: []cli.Command{
+		{
+			Name:                   "get",
+			Usage:                  "Shows task status.",
+			ArgsUsage:              "node:id",
+			Action:                 ctl.NewAction(get),
+			UseShortOptionHandling: true,
+		},
+		{
+			Name:                   "watch",
+			Aliases:                []string{"attach"},
+			Usage:                  "Follows task progress until it completes.",
+			Description:            "Interrupted watch leaves task running, so it can be watched again.",
+			ArgsUsage:              "node:id",
+			Action:                 ctl.NewAction(watch),
+			UseShortOptionHandling: true,
+		},
+		{
+			Name:                   "cancel",
+			Usage:                  "Cancels task.",
+			ArgsUsage:              "node:id",
+			Action:                 ctl.NewAction(cancel),
+			UseShortOptionHandling: true,
+		},
+		{
+			Name:                   "rethrottle",
+			Usage:                  "Changes requests per second of reindex, update by query or delete by query task.",
+			ArgsUsage:              "node:id --requests-per-second 500",
+			Action:                 ctl.NewAction(rethrottle),
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag{
+				cli.Float64Flag{
+					Name:     "requests-per-second, rps",
+					Required: true,
+					Usage:    "Throttle task to `N` requests per second, -1 disables throttling",
+				},
+			},
+		},
+	},
+}
+
+func id(c *cli.Context) string {
+	if !c.Args().Present() {
+		log.Fatal("task not specified")
+	}
+
+	return c.Args().First()
+}
+
+func get(_ app.Config, conn *client.Client, c *cli.Context) error {
+	t, err := conn.Task(context.Background(), id(c))
+	check.Fatalf(err, "get task: %v", err)
+
+	st := Status(t)
+
+	tb := table.New("task", "action", "state", "total", "created", "updated", "deleted", "conflicts", "batches", "rps", "running")
+	tb.Append([]string{
+		t.ID,
+		t.Action,
+		State(t),
+		fmt.Sprint(st.Total),
+		fmt.Sprint(st.Created),
+		fmt.Sprint(st.Updated),
+		fmt.Sprint(st.Deleted),
+		fmt.Sprint(st.VersionConflicts),
+		fmt.Sprint(st.Batches),
+		rps(st.RequestsPerSecond),
+		t.Running.Round(time.Second).String(),
+	})
+	tb.Render()
+
+	if t.Description != "" {
+		log.Printf("%s", t.Description)
+	}
+	Report(t)
+
+	return nil
+}
+
+func watch(_ app.Config, conn *client.Client, c *cli.Context) error {
+	t := Follow(conn, id(c), "watching")
+
+	if !Report(t) {
+		log.Fatalf("task %s failed", t.ID)
+	}
+
+	return nil
+}
+
+func cancel(_ app.Config, conn *client.Client, c *cli.Context) error {
+	err := conn.CancelTask(context.Background(), id(c))
+	check.Fatalf(err, "cancel task: %v", err)
+
+	log.Printf("task %s cancelled", id(c))
+
+	return nil
+}
+
+func rethrottle(_ app.Config, conn *client.Client, c *cli.Context) error {
+	t, err := conn.Task(context.Background(), id(c))
+	check.Fatalf(err, "get task: %v", err)
+
+	err = conn.Rethrottle(context.Background(), t, c.Float64("requests-per-second"))
+	check.Fatalf(err, "rethrottle task: %v", err)
+
+	log.Printf("task %s throttled to %s requests per second", t.ID, rps(c.Float64("requests-per-second")))
+
+	return nil
+}
+
+// Follow renders task progress until it completes.
+// Interrupted process leaves task running, so it is reported to be watched later.
+func Follow(conn *client.Client, id, name string) client.Task {
+	log.Printf("following task %s, it keeps running if interrupted: esctl task watch %s", id, id)
+
+	var conflicts int64
+
+	following, wait := bar.Docs(0, name, bar.Counter("conflicts", func() int64 { return atomic.LoadInt64(&conflicts) }))
+
+	t, err := Track(conn, id, following, func(t client.Task) {
+		atomic.StoreInt64(&conflicts, Status(t).VersionConflicts)
+	})
+	check.Fatalf(err, "watch task %s: %v", id, err)
+
+	wait()
+
+	return t
+}
+
+// Track renders task progress on the bar until task completes.
+// Every poll is reported to fn if set.
+func Track(conn *client.Client, id string, b *mpb.Bar, fn func(client.Task)) (client.Task, error) {
+	started := time.Now()
+
+	var done int64
+	t, err := conn.Watch(context.Background(), id, time.Second, func(t client.Task) {
+		st := Status(t)
+		b.SetTotal(st.Total, false)
+		b.IncrBy(int(st.Done()-done), time.Since(started))
+		done = st.Done()
+
+		if fn != nil {
+			fn(t)
+		}
+	})
+
+	b.SetTotal(done, true)
+
+	return t, err
+}
+
+// Status returns the latest known status of the task.
+func Status(t client.Task) client.TaskStatus {
+	if t.Response != nil {
+		return *t.Response
+	}
+
+	return t.Status
+}
+
+// State describes whether task is running, completed, cancelled or failed.
+func State(t client.Task) string {
+	switch {
+	case t.Error != nil, t.Response != nil && len(t.Response.Failures) > 0:
+		return "failed"
+	case t.Cancelled:
+		return "cancelled"
+	case t.Completed:
+		return "completed"
+	default:
+		return "running"
+	}
+}
+
+// Report prints errors of the task and reports whether it succeeded.
+func Report(t client.Task) bool {
+	if t.Error != nil {
+		log.Printf("task %s: %s: %s", t.ID, t.Error.Type, t.Error.Reason)
+
+		return false
+	}
+	if t.Response == nil || len(t.Response.Failures) == 0 {
+		return true
+	}
+
+	failures := make([]string, len(t.Response.Failures))
+	for i, f := range t.Response.Failures {
+		failures[i] = "\t" + string(f)
+	}
+	log.Printf("task %s has %d failures:\n%s", t.ID, len(failures), strings.Join(failures, "\n"))
+
+	return false
+}
+
+func rps(v float64) string {
+	if v < 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprint(v)
+}
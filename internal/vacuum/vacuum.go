@@ -4,40 +4,39 @@ import (
 	"context"
 	"errors"
 	"io"
-	"io/ioutil"
 	"log"
 	"time"
 
-	"github.com/olivere/elastic/v7"
 	"github.com/unqnown/esctl/internal/app"
 	"github.com/unqnown/esctl/pkg/bar"
 	"github.com/unqnown/esctl/pkg/check"
 	"github.com/unqnown/esctl/pkg/client"
 	"github.com/unqnown/esctl/pkg/ctl"
+	"github.com/unqnown/esctl/pkg/query"
 	"github.com/urfave/cli"
 )
 
 var Command = cli.Command{
 	Name:                   "vacuum",
 	Usage:                  "Gently deletes documents from index.",
-	ArgsUsage:              "[indices...] [--lpr 1000] [--query path/to/query.json]",
+	ArgsUsage:              "[indices...] [--lpr 1000] [--query query.json|'{\"term\":{...}}'|name] [--q 'field:value']",
 	Action:                 ctl.NewAction(vacuum),
 	Category:               "Advanced",
 	UseShortOptionHandling: true,
-	Flags: []cli.Flag{
+	Flags: append(append([]cli.Flag{
 		cli.IntFlag{
 			Name:  "lpr",
 			Usage: "Limit per request",
 			Value: 1000,
 		},
 		cli.StringFlag{
-			Name:  "query, q",
-			Usage: "Query `FILE`",
+			Name:  "dead-letter",
+			Usage: "Write ids of documents failed to delete to `FILE`",
 		},
-	},
+	}, query.Flags...), ctl.BulkFlags...),
 }
 
-func vacuum(_ app.Config, conn *client.Client, c *cli.Context) error {
+func vacuum(conf app.Config, conn *client.Client, c *cli.Context) error {
 	if !c.Args().Present() {
 		log.Fatal("indices not specified")
 	}
@@ -46,19 +45,30 @@ func vacuum(_ app.Config, conn *client.Client, c *cli.Context) error {
 		Size(c.Int("lpr")).
 		FetchSource(false)
 
-	if query := c.String("query"); query != "" {
-		q, err := openQuery(query)
-		check.Fatalf(err, "open query: %v", err)
+	q, err := query.Parse(conf, c)
+	check.Fatalf(err, "parse query: %v", err)
+	if q != nil {
 		scroll.Query(q)
 	}
 
 	started := time.Now()
 
-	vacuum, wait := bar.Docs(0, "vacuum")
+	settings, err := ctl.Bulk(conf, c)
+	check.Fatal(err)
 
-	bulk, err := conn.Bulk()
+	bulk, err := conn.Bulk(settings)
 	check.Fatalf(err, "start bulk processor: %v", err)
 
+	closeDL, err := ctl.DeadLetter(bulk, c)
+	check.Fatalf(err, "create dead letter file: %v", err)
+	defer closeDL()
+
+	stop, err := ctl.Limit(bulk, c)
+	check.Fatalf(err, "limit rate: %v", err)
+	defer stop()
+
+	vacuum, wait := bar.Docs(0, "vacuum", bar.Counter("failed", bulk.Failed))
+
 	for {
 		rsp, err := scroll.Do(context.Background())
 		if err != nil {
@@ -79,19 +89,13 @@ func vacuum(_ app.Config, conn *client.Client, c *cli.Context) error {
 		vacuum.IncrBy(len(rsp.Hits.Hits), time.Since(started))
 	}
 
-	err = bulk.Flush()
+	err = bulk.Close()
 	check.Fatalf(err, "flush remove tasks: %v", err)
 
 	wait()
 
-	return nil
-}
-
-func openQuery(path string) (elastic.Query, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+	err = ctl.Rejected(bulk, c)
+	check.Fatalf(err, "vacuum: %v", err)
 
-	return elastic.NewRawStringQuery(string(data)), nil
+	return nil
 }
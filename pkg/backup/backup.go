@@ -1,7 +1,12 @@
 package backup
 
 type Document struct {
-	ID    string      `json:"_id"`
-	Index string      `json:"index"`
-	Body  interface{} `json:"body"`
+	ID      string `json:"_id"`
+	Index   string `json:"index"`
+	Routing string `json:"_routing,omitempty"`
+	// Version, SeqNo and PrimaryTerm hold document metadata at the moment of dump.
+	Version     *int64      `json:"_version,omitempty"`
+	SeqNo       *int64      `json:"_seq_no,omitempty"`
+	PrimaryTerm *int64      `json:"_primary_term,omitempty"`
+	Body        interface{} `json:"body"`
 }
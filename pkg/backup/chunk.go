@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Chunk limits size of a dump part. Zero limit means unlimited.
+type Chunk struct {
+	Bytes int64
+	Docs  int64
+}
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseChunk parses chunk size: either amount of bytes with optional unit, e.g. 1GB,
+// or amount of documents suffixed with docs, e.g. 1000000docs.
+func ParseChunk(s string) (Chunk, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+
+	if n := strings.TrimSuffix(v, "DOCS"); n != v {
+		docs, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil || docs <= 0 {
+			return Chunk{}, fmt.Errorf("malformed chunk size %q", s)
+		}
+
+		return Chunk{Docs: docs}, nil
+	}
+
+	size := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			v, size = strings.TrimSuffix(v, u.suffix), u.size
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n <= 0 {
+		return Chunk{}, fmt.Errorf("malformed chunk size %q", s)
+	}
+
+	return Chunk{Bytes: int64(n * float64(size))}, nil
+}
+
+// Full reports whether part of given size holding given amount of documents reached the limit.
+func (c Chunk) Full(size, docs int64) bool {
+	return (c.Bytes > 0 && size >= c.Bytes) || (c.Docs > 0 && docs >= c.Docs)
+}
+
+const partPrefix = "part-"
+
+// PartName returns name of the numbered dump part.
+func PartName(part int, encoding, compression string) string {
+	return fmt.Sprintf("%s%05d%s%s", partPrefix, part, FormatExt(encoding), Ext(compression))
+}
+
+// Parts returns parts of chunked dump stored in dir ordered by their numbers.
+func Parts(dir string) ([]string, error) {
+	parts, err := filepath.Glob(filepath.Join(dir, partPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(parts)
+
+	return parts, nil
+}
@@ -0,0 +1,57 @@
+package backup
+
+import "testing"
+
+func TestParseChunk(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Chunk
+	}{
+		{"1GB", Chunk{Bytes: 1 << 30}},
+		{"1.5mb", Chunk{Bytes: 3 << 19}},
+		{"512 KB", Chunk{Bytes: 512 << 10}},
+		{"2TB", Chunk{Bytes: 2 << 40}},
+		{"100B", Chunk{Bytes: 100}},
+		{"4096", Chunk{Bytes: 4096}},
+		{"1000000docs", Chunk{Docs: 1000000}},
+		{" 10 DOCS ", Chunk{Docs: 10}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseChunk(tt.s)
+		if err != nil {
+			t.Errorf("ParseChunk(%q): %v", tt.s, err)
+
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseChunk(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "GB", "0", "-1MB", "1.5docs", "0docs", "ten", "1PB"} {
+		if _, err := ParseChunk(s); err == nil {
+			t.Errorf("ParseChunk(%q): error expected", s)
+		}
+	}
+}
+
+func TestChunkFull(t *testing.T) {
+	tests := []struct {
+		chunk     Chunk
+		size, doc int64
+		want      bool
+	}{
+		{Chunk{Bytes: 100}, 99, 1000, false},
+		{Chunk{Bytes: 100}, 100, 0, true},
+		{Chunk{Docs: 10}, 1 << 40, 9, false},
+		{Chunk{Docs: 10}, 0, 10, true},
+		{Chunk{}, 1 << 40, 1 << 40, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.chunk.Full(tt.size, tt.doc); got != tt.want {
+			t.Errorf("%+v.Full(%d, %d) = %v, want %v", tt.chunk, tt.size, tt.doc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported compression algorithms.
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Ext returns file extension of given compression algorithm.
+func Ext(compression string) string {
+	switch compression {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+type plain struct{ io.Writer }
+
+func (p *plain) Close() error      { return nil }
+func (p *plain) Reset(w io.Writer) { p.Writer = w }
+
+// Writer compresses dump content.
+// Every Flush ends a compressed frame, so everything written
+// before it can be decompressed independently of what follows.
+type Writer struct {
+	w io.Writer
+	c compressor
+}
+
+// NewWriter returns writer which compresses data with given algorithm.
+func NewWriter(w io.Writer, compression string) (*Writer, error) {
+	var c compressor
+	switch compression {
+	case None:
+		c = &plain{Writer: w}
+	case Gzip:
+		c = gzip.NewWriter(w)
+	case Zstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		c = enc
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+
+	return &Writer{w: w, c: c}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) { return w.c.Write(p) }
+
+// Flush ends current compressed frame and starts a new one.
+func (w *Writer) Flush() error {
+	if err := w.c.Close(); err != nil {
+		return err
+	}
+	w.c.Reset(w.w)
+
+	return nil
+}
+
+// Close ends current compressed frame.
+// It does not close underlying writer.
+func (w *Writer) Close() error { return w.c.Close() }
+
+// NewReader returns reader which decompresses data
+// with an algorithm detected by its magic bytes.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return dec.IOReadCloser(), nil
+	default:
+		return ioutil.NopCloser(br), nil
+	}
+}
@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Supported document encodings.
+const (
+	// JSON is esctl's own format: a document with its metadata per record.
+	JSON = "json"
+	// Bulk is an _bulk API request body: action line followed by source line.
+	Bulk = "bulk"
+	// CSV is a table of flattened document fields.
+	CSV = "csv"
+	// Parquet is a columnar table of flattened document fields.
+	Parquet = "parquet"
+)
+
+// Pseudo columns which refer to document metadata.
+const (
+	ColumnID      = "_id"
+	ColumnIndex   = "_index"
+	ColumnRouting = "_routing"
+)
+
+// ErrUnsupportedEncoding is returned for encodings esctl can't write or read.
+var ErrUnsupportedEncoding = errors.New("unsupported encoding")
+
+// FormatExt returns file extension of given encoding.
+func FormatExt(encoding string) string {
+	switch encoding {
+	case Bulk:
+		return ".ndjson"
+	case CSV:
+		return ".csv"
+	case Parquet:
+		return ".parquet"
+	default:
+		return ".json"
+	}
+}
+
+// Tabular reports whether encoding stores documents as table rows.
+// Tabular dumps can't be restored.
+func Tabular(encoding string) bool { return encoding == CSV || encoding == Parquet }
+
+// Encoder writes documents in one of supported encodings.
+type Encoder interface {
+	Encode(doc Document) error
+	// Flush writes buffered documents to the underlying writer.
+	Flush() error
+	// Close completes encoded file.
+	Close() error
+}
+
+// EncoderOptions configures encoder.
+type EncoderOptions struct {
+	// Plain disables json indentation.
+	Plain bool
+	// Columns are dot separated fields of tabular encodings.
+	Columns []string
+	// Header enables csv header.
+	Header bool
+}
+
+// NewEncoder returns encoder of given encoding.
+func NewEncoder(w io.Writer, encoding string, opts EncoderOptions) (Encoder, error) {
+	switch encoding {
+	case "", JSON:
+		enc := json.NewEncoder(w)
+		if !opts.Plain {
+			enc.SetIndent("", "	")
+		}
+		return &jsonEncoder{enc: enc}, nil
+	case Bulk:
+		return &bulkEncoder{enc: json.NewEncoder(w)}, nil
+	case CSV:
+		if len(opts.Columns) == 0 {
+			return nil, errors.New("csv: no columns")
+		}
+		enc := &csvEncoder{w: csv.NewWriter(w), columns: opts.Columns}
+		if opts.Header {
+			if err := enc.w.Write(opts.Columns); err != nil {
+				return nil, err
+			}
+		}
+		return enc, nil
+	case Parquet:
+		if len(opts.Columns) == 0 {
+			return nil, errors.New("parquet: no columns")
+		}
+		schema := make([]string, len(opts.Columns))
+		for i, col := range opts.Columns {
+			schema[i] = fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", col)
+		}
+		pw, err := writer.NewCSVWriterFromWriter(schema, w, 1)
+		if err != nil {
+			return nil, fmt.Errorf("parquet: %w", err)
+		}
+		return &parquetEncoder{w: pw, columns: opts.Columns}, nil
+	default:
+		return nil, fmt.Errorf("%q: %w", encoding, ErrUnsupportedEncoding)
+	}
+}
+
+type jsonEncoder struct{ enc *json.Encoder }
+
+func (e *jsonEncoder) Encode(doc Document) error { return e.enc.Encode(doc) }
+func (e *jsonEncoder) Flush() error              { return nil }
+func (e *jsonEncoder) Close() error              { return nil }
+
+// Action is a metadata line of _bulk request.
+type Action struct {
+	Index   string `json:"_index,omitempty"`
+	ID      string `json:"_id,omitempty"`
+	Routing string `json:"routing,omitempty"`
+}
+
+type bulkEncoder struct{ enc *json.Encoder }
+
+func (e *bulkEncoder) Encode(doc Document) error {
+	action := map[string]Action{"index": {Index: doc.Index, ID: doc.ID, Routing: doc.Routing}}
+	if err := e.enc.Encode(action); err != nil {
+		return err
+	}
+
+	return e.enc.Encode(doc.Body)
+}
+
+func (e *bulkEncoder) Flush() error { return nil }
+func (e *bulkEncoder) Close() error { return nil }
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func (e *csvEncoder) Encode(doc Document) error {
+	row, err := flatten(doc, e.columns)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(row))
+	for i, v := range row {
+		if v != nil {
+			record[i] = *v
+		}
+	}
+
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error { return e.Flush() }
+
+// parquetEncoder buffers rows into row groups,
+// so nothing but Close completes the file.
+type parquetEncoder struct {
+	w       *writer.CSVWriter
+	columns []string
+}
+
+func (e *parquetEncoder) Encode(doc Document) error {
+	row, err := flatten(doc, e.columns)
+	if err != nil {
+		return err
+	}
+
+	return e.w.WriteString(row)
+}
+
+func (e *parquetEncoder) Flush() error { return nil }
+func (e *parquetEncoder) Close() error { return e.w.WriteStop() }
+
+// flatten picks columns of the document. Missing fields are nil,
+// objects and arrays are encoded as json.
+func flatten(doc Document, columns []string) ([]*string, error) {
+	body, err := object(doc.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", doc.Index, doc.ID, err)
+	}
+
+	row := make([]*string, len(columns))
+	for i, col := range columns {
+		var v interface{}
+		switch col {
+		case ColumnID:
+			v = doc.ID
+		case ColumnIndex:
+			v = doc.Index
+		case ColumnRouting:
+			if doc.Routing == "" {
+				continue
+			}
+			v = doc.Routing
+		default:
+			var found bool
+			if v, found = lookup(body, col); !found || v == nil {
+				continue
+			}
+		}
+
+		s, err := stringify(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %s: %w", doc.Index, doc.ID, col, err)
+		}
+		row[i] = &s
+	}
+
+	return row, nil
+}
+
+func object(body interface{}) (map[string]interface{}, error) {
+	var raw []byte
+	switch b := body.(type) {
+	case map[string]interface{}:
+		return b, nil
+	case json.RawMessage:
+		raw = b
+	default:
+		var err error
+		if raw, err = json.Marshal(b); err != nil {
+			return nil, err
+		}
+	}
+
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	// keep long numbers intact.
+	dec.UseNumber()
+
+	return obj, dec.Decode(&obj)
+}
+
+// lookup finds dot separated field. Keys containing dots are matched as is.
+func lookup(obj map[string]interface{}, field string) (interface{}, bool) {
+	if v, ok := obj[field]; ok {
+		return v, true
+	}
+
+	for i := strings.IndexByte(field, '.'); i >= 0; i = next(field, i) {
+		nested, ok := obj[field[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := lookup(nested, field[i+1:]); ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func next(field string, i int) int {
+	j := strings.IndexByte(field[i+1:], '.')
+	if j < 0 {
+		return -1
+	}
+
+	return i + 1 + j
+}
+
+func stringify(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// Fields returns leaf fields of indices mappings ordered by name.
+// Fields of nested objects are dot separated.
+func Fields(indices map[string]Index) []string {
+	seen := make(map[string]bool)
+	for _, ind := range indices {
+		properties(ind.Mappings, "", seen)
+	}
+
+	fields := make([]string, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+func properties(mapping map[string]interface{}, prefix string, fields map[string]bool) {
+	props, _ := mapping["properties"].(map[string]interface{})
+	for name, p := range props {
+		field, _ := p.(map[string]interface{})
+		if _, nested := field["properties"]; nested {
+			properties(field, prefix+name+".", fields)
+
+			continue
+		}
+		fields[prefix+name] = true
+	}
+}
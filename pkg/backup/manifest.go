@@ -0,0 +1,265 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format is a version of the dump format.
+// It is increased on every incompatible change.
+const Format = 1
+
+// Manifest describes dump content.
+type Manifest struct {
+	Format      int             `json:"format"`
+	Esctl       string          `json:"esctl,omitempty"`
+	Created     time.Time       `json:"created"`
+	Completed   *time.Time      `json:"completed,omitempty"`
+	Source      Source          `json:"source"`
+	Query       json.RawMessage `json:"query,omitempty"`
+	Filter      *Filter         `json:"filter,omitempty"`
+	Transform   *Transform      `json:"transform,omitempty"`
+	Compression string          `json:"compression,omitempty"`
+	// Encoding is a document encoding, json if empty.
+	Encoding string   `json:"encoding,omitempty"`
+	Columns  []string `json:"columns,omitempty"`
+	// Sort, Limit and Sample describe partial dump.
+	Sort   []string `json:"sort,omitempty"`
+	Limit  int64    `json:"limit,omitempty"`
+	Sample string   `json:"sample,omitempty"`
+	// Seed is a seed of random sample.
+	Seed int64 `json:"seed,omitempty"`
+	// Docs is a total amount of dumped documents.
+	Docs    int64            `json:"docs"`
+	Indices map[string]Index `json:"indices"`
+	Files   []File           `json:"files,omitempty"`
+}
+
+// Source describes cluster dump is taken from.
+type Source struct {
+	Context string `json:"context,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+	UUID    string `json:"uuid,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Filter describes source fields dump is restricted to.
+type Filter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+// Transform describes rename, set and drop rules applied in order.
+type Transform struct {
+	Rename []string `json:"rename,omitempty"`
+	Set    []string `json:"set,omitempty"`
+	Drop   []string `json:"drop,omitempty"`
+}
+
+// Empty reports whether documents are kept as is.
+func (t Transform) Empty() bool { return len(t.Rename)+len(t.Set)+len(t.Drop) == 0 }
+
+// String describes rules the way they are passed to esctl.
+func (t Transform) String() string {
+	var rules []string
+	for _, r := range t.Rename {
+		rules = append(rules, "--rename "+r)
+	}
+	for _, s := range t.Set {
+		rules = append(rules, "--set "+s)
+	}
+	for _, d := range t.Drop {
+		rules = append(rules, "--drop "+d)
+	}
+
+	return strings.Join(rules, " ")
+}
+
+// Includes returns include patterns together with exact fields.
+func (f Filter) Includes() []string { return append(append([]string(nil), f.Include...), f.Fields...) }
+
+// Empty reports whether filter keeps whole documents.
+func (f Filter) Empty() bool { return len(f.Include)+len(f.Exclude)+len(f.Fields) == 0 }
+
+// Keeps reports whether field passes the filter.
+// Patterns match the field itself or any of its parent objects.
+func (f Filter) Keeps(field string) bool {
+	includes := f.Includes()
+	if len(includes) > 0 && !matchAny(includes, field) {
+		return false
+	}
+
+	return !matchAny(f.Exclude, field)
+}
+
+func matchAny(patterns []string, field string) bool {
+	for _, pattern := range patterns {
+		for path := field; ; {
+			if ok, _ := filepath.Match(pattern, path); ok {
+				return true
+			}
+			i := strings.LastIndexByte(path, '.')
+			if i < 0 {
+				break
+			}
+			path = path[:i]
+		}
+	}
+
+	return false
+}
+
+// Index holds index configuration required to recreate it.
+type Index struct {
+	Docs     int64                  `json:"docs"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// File describes file dump is stored in.
+type File struct {
+	// Name is a file path relative to manifest.
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+const (
+	manifestExt = ".manifest.json"
+	// manifestName is a name of the manifest within chunked dump directory.
+	manifestName = "manifest.json"
+)
+
+// ManifestPath returns path of the manifest which accompanies given dump.
+// Manifest of chunked dump is stored within its directory.
+// Manifest path itself is returned as is.
+func ManifestPath(dump string) string {
+	if strings.HasSuffix(dump, manifestExt) || filepath.Base(dump) == manifestName {
+		return dump
+	}
+	if s, err := os.Stat(dump); err == nil && s.IsDir() {
+		return filepath.Join(dump, manifestName)
+	}
+
+	return dump + manifestExt
+}
+
+// Open returns manifest and files of the dump.
+// Path is either a dump file, a chunked dump directory or a manifest.
+// Manifest is nil if dump has none.
+func Open(path string) (*Manifest, []string, error) {
+	s, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mp := ManifestPath(path)
+
+	m, err := OpenManifest(mp)
+	switch {
+	case os.IsNotExist(err) && s.IsDir():
+		files, err := Parts(path)
+		return nil, files, err
+	case os.IsNotExist(err):
+		return nil, []string{path}, nil
+	case err != nil:
+		return nil, nil, err
+	}
+
+	switch {
+	case !s.IsDir() && path != mp:
+		return &m, []string{path}, nil
+	case len(m.Files) == 0 && strings.HasSuffix(mp, manifestExt):
+		// manifest predates file descriptions.
+		return &m, []string{strings.TrimSuffix(mp, manifestExt)}, nil
+	}
+
+	files := make([]string, len(m.Files))
+	for i, f := range m.Files {
+		files[i] = filepath.Join(filepath.Dir(mp), f.Name)
+	}
+
+	return &m, files, nil
+}
+
+// OpenManifest reads manifest from file.
+func OpenManifest(path string) (m Manifest, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return m, err
+	}
+	defer f.Close()
+
+	return m, json.NewDecoder(f).Decode(&m)
+}
+
+// Save writes manifest to file.
+func (m Manifest) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "	")
+
+	return enc.Encode(m)
+}
+
+// Validate checks whether manifest describes dump esctl is able to restore.
+func (m Manifest) Validate() error {
+	if m.Format > Format {
+		return fmt.Errorf("unsupported dump format %d: esctl supports formats up to %d", m.Format, Format)
+	}
+	if Tabular(m.Encoding) {
+		return fmt.Errorf("%s dump: %w", m.Encoding, ErrUnsupportedEncoding)
+	}
+
+	return nil
+}
+
+// Partial reports whether dump holds only some of matching documents.
+func (m Manifest) Partial() bool { return m.Limit > 0 || m.Sample != "" }
+
+// File returns description of the given dump file.
+func (m Manifest) File(path string) (File, bool) {
+	name := filepath.Base(path)
+	for _, f := range m.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return File{}, false
+}
+
+// Describe computes size and checksum of the dump file.
+func Describe(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{
+		Name:   filepath.Base(path),
+		Size:   n,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
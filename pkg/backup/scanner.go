@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Record is a raw dumped document.
+// Record of bulk dump holds its action line.
+type Record struct {
+	Action json.RawMessage
+	Raw    json.RawMessage
+}
+
+// Decode decodes document of the record keeping long numbers intact.
+func (r Record) Decode() (doc Document, err error) {
+	if r.Action == nil {
+		return doc, decode(r.Raw, &doc)
+	}
+
+	var action map[string]Action
+	if err := json.Unmarshal(r.Action, &action); err != nil {
+		return doc, err
+	}
+	for _, a := range action {
+		doc.Index, doc.ID, doc.Routing = a.Index, a.ID, a.Routing
+	}
+
+	return doc, decode(r.Raw, &doc.Body)
+}
+
+func decode(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// Scanner splits dump stream into records.
+// Encoding of the stream, json or bulk, is detected by its first record.
+type Scanner struct {
+	dec  *json.Decoder
+	bulk *bool
+}
+
+func NewScanner(r io.Reader) *Scanner { return &Scanner{dec: json.NewDecoder(r)} }
+
+// Scan returns the next record or io.EOF at the end of the stream.
+func (s *Scanner) Scan() (r Record, err error) {
+	var raw json.RawMessage
+	if err := s.dec.Decode(&raw); err != nil {
+		return r, err
+	}
+
+	if s.bulk == nil {
+		bulk := isAction(raw)
+		s.bulk = &bulk
+	}
+	if !*s.bulk {
+		return Record{Raw: raw}, nil
+	}
+
+	if !isAction(raw) {
+		return r, fmt.Errorf("unsupported bulk action %s", raw)
+	}
+
+	r.Action = raw
+	if err := s.dec.Decode(&r.Raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return r, io.ErrUnexpectedEOF
+		}
+		return r, err
+	}
+
+	return r, nil
+}
+
+// isAction reports whether raw is an index or create action of _bulk request.
+func isAction(raw json.RawMessage) bool {
+	var action map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &action); err != nil || len(action) != 1 {
+		return false
+	}
+	for op, meta := range action {
+		if op != "index" && op != "create" {
+			return false
+		}
+		var a map[string]interface{}
+		if err := json.Unmarshal(meta, &a); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Read passes records of possibly compressed dump stream to fn
+// and returns checksum and size of the stream as it is stored.
+func Read(r io.Reader, fn func(Record) error) (sum string, size int64, err error) {
+	h := sha256.New()
+	cnt := new(counter)
+	src := io.TeeReader(r, io.MultiWriter(h, cnt))
+
+	dr, err := NewReader(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer dr.Close()
+
+	sc := NewScanner(dr)
+	for {
+		rec, err := sc.Scan()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		if err := fn(rec); err != nil {
+			return "", 0, err
+		}
+	}
+
+	// consume the rest of the stream to complete checksum.
+	if _, err := io.Copy(ioutil.Discard, dr); err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(ioutil.Discard, src); err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), cnt.n, nil
+}
+
+// counter counts bytes written.
+type counter struct{ n int64 }
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+
+	return len(p), nil
+}
@@ -1,36 +1,96 @@
 package bar
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 )
 
-func Docs(total int64, name string) (*mpb.Bar, func()) {
-	p := mpb.New(mpb.WithWidth(80))
-	bar := p.AddBar(total,
-		mpb.PrependDecorators(
-			decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DidentRight}),
-			decor.Elapsed(decor.ET_STYLE_HHMMSS, decor.WC{W: 9}),
-			decor.Name("/"),
-			decor.AverageETA(decor.ET_STYLE_HHMMSS, decor.WC{W: 9, C: decor.DidentRight}),
-		),
-		mpb.AppendDecorators(decor.CountersNoUnit("%d / %d")),
-	)
+func Docs(total int64, name string, extra ...decor.Decorator) (*mpb.Bar, func()) {
+	p := New()
+
+	return p.Docs(total, name, extra...), p.Wait
+}
+
+func Percent(total int64, name string, extra ...decor.Decorator) (*mpb.Bar, func()) {
+	p := New()
+
+	return p.Percent(total, name, extra...), p.Wait
+}
+
+// Progress renders several bars at once.
+// Bars are rendered to stderr, so stdout remains usable for piping.
+type Progress struct{ p *mpb.Progress }
+
+func New() *Progress { return &Progress{p: mpb.New(mpb.WithWidth(80), mpb.WithOutput(os.Stderr))} }
+
+// Docs adds bar which counts documents.
+func (p *Progress) Docs(total int64, name string, extra ...decor.Decorator) *mpb.Bar {
+	return p.add(total, name, decor.CountersNoUnit("%d / %d"), extra)
+}
+
+// Percent adds bar which shows percentage.
+func (p *Progress) Percent(total int64, name string, extra ...decor.Decorator) *mpb.Bar {
+	return p.add(total, name, decor.Percentage(), extra)
+}
+
+// Transient adds percentage bar which disappears once complete.
+func (p *Progress) Transient(total int64, name string, extra ...decor.Decorator) *mpb.Bar {
+	return p.add(total, name, decor.Percentage(), extra, mpb.BarRemoveOnComplete())
+}
 
-	return bar, p.Wait
+// Stream adds bar which shows amount of bytes read from a stream of unknown size.
+// It is completed with SetTotal.
+func (p *Progress) Stream(name string, extra ...decor.Decorator) *mpb.Bar {
+	return p.add(0, name, Read(), extra)
 }
 
-func Percent(total int64, name string) (*mpb.Bar, func()) {
-	p := mpb.New(mpb.WithWidth(80))
-	bar := p.AddBar(total,
+// Wait waits for all bars to complete.
+func (p *Progress) Wait() { p.p.Wait() }
+
+func (p *Progress) add(total int64, name string, counter decor.Decorator, extra []decor.Decorator, opts ...mpb.BarOption) *mpb.Bar {
+	return p.p.AddBar(total, append([]mpb.BarOption{
 		mpb.PrependDecorators(
-			decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DidentRight}),
+			// names of all bars are aligned.
+			decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DSyncWidthR}),
 			decor.Elapsed(decor.ET_STYLE_HHMMSS, decor.WC{W: 9}),
 			decor.Name("/"),
 			decor.AverageETA(decor.ET_STYLE_HHMMSS, decor.WC{W: 9, C: decor.DidentRight}),
 		),
-		mpb.AppendDecorators(decor.Percentage()),
-	)
+		mpb.AppendDecorators(append([]decor.Decorator{counter}, extra...)...),
+	}, opts...)...)
+}
+
+// Counter returns decorator which shows named value reported by count.
+func Counter(name string, count func() int64) decor.Decorator {
+	wc := decor.WC{}
+	wc.Init()
+
+	return &counter{WC: wc, name: name, count: count}
+}
+
+type counter struct {
+	decor.WC
+	name  string
+	count func() int64
+}
+
+func (c *counter) Decor(*decor.Statistics) string {
+	return c.FormatMsg(fmt.Sprintf(" %s: %d", c.name, c.count()))
+}
+
+// Read returns decorator which shows amount of bytes read.
+func Read() decor.Decorator {
+	wc := decor.WC{}
+	wc.Init()
+
+	return &read{WC: wc}
+}
+
+type read struct{ decor.WC }
 
-	return bar, p.Wait
+func (r *read) Decor(st *decor.Statistics) string {
+	return r.FormatMsg(fmt.Sprintf("% .1f", decor.CounterKiB(st.Current)))
 }
@@ -0,0 +1,396 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/backup"
+)
+
+const (
+	// retries is an amount of attempts to save rejected document.
+	retries = 5
+	// cooldown is a pause before retrying rejected documents.
+	cooldown = time.Second
+)
+
+// Bulk processor defaults.
+const (
+	workers       = 20
+	actions       = 100
+	size          = 2000 * 100
+	flushInterval = time.Second
+)
+
+// retryable holds statuses of items cluster is unable to process right now.
+var retryable = map[int]bool{408: true, 429: true, 503: true, 507: true}
+
+func (cli *Client) Bulk(conf app.Bulk) (*Bulker, error) { return NewBulker(cli.Client, conf) }
+
+type Bulker struct {
+	*elastic.BulkProcessor
+
+	// VersionType enables replaying of saved documents versions if set.
+	// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-index_.html#index-version-types
+	VersionType string
+	// DeadLetter receives documents rejected by cluster if set.
+	DeadLetter io.Writer
+
+	failed int64
+
+	throttle *throttle
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	reasons map[string]int64
+	pending []*request
+	retryAt time.Time
+	// lost holds requests of failed bulk commits by error.
+	// Processor keeps them and sends again with the next commit of the worker,
+	// so they are rejected only if none of the commits succeeds.
+	lost map[*request]string
+}
+
+// request tracks document through attempts to save it.
+type request struct {
+	elastic.BulkableRequest
+
+	doc     backup.Document
+	attempt int
+}
+
+// Failure describes document rejected by cluster.
+// Dead letter file consisting of failures can be restored as a dump.
+type Failure struct {
+	backup.Document
+
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// NewBulker starts bulk processor. Zero settings fall back to defaults.
+func NewBulker(cli *elastic.Client, conf app.Bulk) (*Bulker, error) {
+	conf = app.Bulk{
+		Workers:       workers,
+		Actions:       actions,
+		Size:          size,
+		FlushInterval: flushInterval,
+	}.Merge(conf)
+
+	b := Bulker{
+		reasons:  make(map[string]int64),
+		lost:     make(map[*request]string),
+		throttle: &throttle{adaptive: conf.Adaptive},
+	}
+
+	processor, err := cli.BulkProcessor().
+		After(b.after).
+		Name("esctl_bulk_processor").
+		Workers(conf.Workers).
+		BulkActions(conf.Actions).
+		BulkSize(conf.Size).
+		FlushInterval(conf.FlushInterval).
+		// rejected items are retried by bulker,
+		// so every response item corresponds to request.
+		RetryItemStatusCodes().
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	b.BulkProcessor = processor
+
+	return &b, nil
+}
+
+func (b *Bulker) after(execID int64, reqs []elastic.BulkableRequest, rsp *elastic.BulkResponse, err error) {
+	if err != nil {
+		log.Printf("bulk processing: executing [%d]: %v", execID, err)
+
+		if elastic.IsStatusCode(err, 429) {
+			b.backoff()
+		}
+
+		for _, req := range reqs {
+			b.lose(req, err)
+		}
+
+		return
+	}
+
+	b.recover(reqs)
+
+	if rsp == nil || !rsp.Errors {
+		b.rampUp()
+
+		return
+	}
+
+	overloaded := false
+
+	for i, item := range rsp.Items {
+		for op, res := range item {
+			if res.Status < 300 {
+				continue
+			}
+			if op == "delete" && res.Status == 404 {
+				// document is already gone.
+				continue
+			}
+
+			if res.Status == 429 || (res.Error != nil && res.Error.Type == "es_rejected_execution_exception") {
+				overloaded = true
+			}
+
+			if r, ok := reqs[i].(*request); ok && retryable[res.Status] && r.attempt < retries {
+				b.retry(r)
+
+				continue
+			}
+
+			reason, details := "unknown", ""
+			if res.Error != nil {
+				reason, details = res.Error.Type, res.Error.Reason
+			}
+			b.fail(reqs[i], res.Status, reason, details)
+		}
+	}
+
+	if overloaded {
+		b.backoff()
+	} else {
+		b.rampUp()
+	}
+}
+
+func (b *Bulker) backoff() { b.throttle.backoff() }
+
+func (b *Bulker) rampUp() { b.throttle.rampUp() }
+
+// Limit returns current limit of documents per second.
+// Zero means unlimited.
+func (b *Bulker) Limit() int64 { return int64(b.throttle.Limit()) }
+
+// Rate returns static rate limits.
+func (b *Bulker) Rate() Rate { return b.throttle.Rate() }
+
+// SetRate sets static rate limits. It is safe to call while processor is running.
+func (b *Bulker) SetRate(r Rate) { b.throttle.SetRate(r) }
+
+func (b *Bulker) retry(r *request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r.attempt++
+	b.pending = append(b.pending, r)
+	b.retryAt = time.Now().Add(cooldown)
+}
+
+// lose marks request of failed commit as outstanding.
+func (b *Bulker) lose(req elastic.BulkableRequest, err error) {
+	r, ok := req.(*request)
+	if !ok {
+		b.fail(req, 0, "transport", err.Error())
+
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lost[r] = err.Error()
+}
+
+// recover clears requests which have reached cluster.
+func (b *Bulker) recover(reqs []elastic.BulkableRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, req := range reqs {
+		if r, ok := req.(*request); ok {
+			delete(b.lost, r)
+		}
+	}
+}
+
+func (b *Bulker) fail(req elastic.BulkableRequest, status int, reason, details string) {
+	atomic.AddInt64(&b.failed, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reasons[reason]++
+
+	if b.DeadLetter == nil {
+		return
+	}
+	if b.enc == nil {
+		b.enc = json.NewEncoder(b.DeadLetter)
+	}
+
+	var doc backup.Document
+	if r, ok := req.(*request); ok {
+		doc = r.doc
+	}
+	if details == "" {
+		details = reason
+	}
+
+	if err := b.enc.Encode(Failure{Document: doc, Status: status, Error: details}); err != nil {
+		log.Printf("write dead letter: %v", err)
+	}
+}
+
+// Failed returns amount of documents rejected by cluster.
+func (b *Bulker) Failed() int64 { return atomic.LoadInt64(&b.failed) }
+
+// Reasons returns amount of rejected documents by failure reason.
+func (b *Bulker) Reasons() map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reasons := make(map[string]int64, len(b.reasons))
+	for r, n := range b.reasons {
+		reasons[r] = n
+	}
+
+	return reasons
+}
+
+// Err returns error which summarizes rejected documents if any.
+func (b *Bulker) Err() error {
+	failed := b.Failed()
+	if failed == 0 {
+		return nil
+	}
+
+	reasons := b.Reasons()
+
+	summary := make([]string, 0, len(reasons))
+	for r, n := range reasons {
+		summary = append(summary, fmt.Sprintf("%s: %d", r, n))
+	}
+	sort.Strings(summary)
+
+	return fmt.Errorf("%d documents rejected (%s)", failed, strings.Join(summary, ", "))
+}
+
+// requeue adds rejected requests back to processor once cooldown passed.
+// Requests are added from caller goroutine, not from processor callback,
+// so processor workers never wait for themselves.
+func (b *Bulker) requeue(force bool) bool {
+	b.mu.Lock()
+	if len(b.pending) == 0 || (!force && time.Now().Before(b.retryAt)) {
+		b.mu.Unlock()
+
+		return false
+	}
+	if wait := time.Until(b.retryAt); wait > 0 {
+		time.Sleep(wait)
+	}
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	for _, r := range pending {
+		b.BulkProcessor.Add(r)
+	}
+
+	return true
+}
+
+// Add adds request to processor.
+func (b *Bulker) Add(req elastic.BulkableRequest) {
+	b.requeue(false)
+	b.throttle.wait(sizeOf(req))
+	b.BulkProcessor.Add(req)
+}
+
+// sizeOf returns size of request body in bytes.
+func sizeOf(req elastic.BulkableRequest) int {
+	lines, err := req.Source()
+	if err != nil {
+		// processor reports malformed request itself.
+		return 0
+	}
+
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+
+	return size
+}
+
+// Close flushes all requests including retried ones and stops processor.
+func (b *Bulker) Close() error {
+	for {
+		if err := b.BulkProcessor.Flush(); err != nil {
+			return err
+		}
+		if !b.requeue(true) {
+			break
+		}
+	}
+
+	err := b.BulkProcessor.Close()
+
+	// requests of failed commits which were never sent successfully.
+	b.mu.Lock()
+	lost := b.lost
+	b.lost = make(map[*request]string)
+	b.mu.Unlock()
+
+	for r, reason := range lost {
+		b.fail(r, 0, "transport", reason)
+	}
+
+	return err
+}
+
+// Add adds requests to remove given ids.
+func (b *Bulker) Rm(index string, ids ...string) {
+	for _, id := range ids {
+		b.rm(index, id)
+	}
+}
+
+func (b *Bulker) rm(index string, id string) {
+	b.Add(&request{
+		BulkableRequest: elastic.NewBulkDeleteRequest().
+			Index(index).
+			Id(id),
+		doc: backup.Document{ID: id, Index: index},
+	})
+}
+
+func (b *Bulker) Save(docs ...backup.Document) {
+	for _, doc := range docs {
+		b.save(doc)
+	}
+}
+
+func (b *Bulker) save(doc backup.Document) {
+	req := elastic.NewBulkIndexRequest().
+		Index(doc.Index).
+		Id(doc.ID).
+		Doc(doc.Body)
+	if doc.Routing != "" {
+		req.Routing(doc.Routing)
+	}
+	if b.VersionType != "" && doc.Version != nil {
+		req.VersionType(b.VersionType).Version(*doc.Version)
+	}
+
+	b.Add(&request{BulkableRequest: req, doc: doc})
+}
@@ -2,12 +2,16 @@ package client
 
 import (
 	"context"
-	"log"
-	"time"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 
 	"github.com/olivere/elastic/v7"
 	"github.com/unqnown/esctl/internal/app"
-	"github.com/unqnown/esctl/pkg/backup"
 )
 
 type Client struct {
@@ -15,10 +19,23 @@ type Client struct {
 }
 
 func New(cluster app.Cluster, usr app.User) (*Client, error) {
-	cli, err := elastic.NewSimpleClient(
+	transport, err := Transport(cluster.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []elastic.ClientOptionFunc{
 		elastic.SetURL(cluster.Servers...),
-		elastic.SetBasicAuth(usr.Name, usr.Password),
-	)
+		elastic.SetHttpClient(&http.Client{Transport: transport}),
+	}
+	if headers := Headers(cluster, usr); len(headers) > 0 {
+		opts = append(opts, elastic.SetHeaders(headers))
+	}
+	if usr.APIKey == "" {
+		opts = append(opts, elastic.SetBasicAuth(usr.Name, usr.Password))
+	}
+
+	cli, err := elastic.NewSimpleClient(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,60 +43,89 @@ func New(cluster app.Cluster, usr app.User) (*Client, error) {
 	return &Client{Client: cli}, nil
 }
 
-func (cli *Client) Bulk() (*Bulker, error) { return NewBulker(cli.Client) }
-
-type Bulker struct {
-	*elastic.BulkProcessor
-}
+// Transport returns http transport trusting cluster certificate authorities.
+func Transport(conf *app.TLS) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf == nil {
+		return transport, nil
+	}
 
-func NewBulker(cli *elastic.Client) (*Bulker, error) {
-	processor, err := cli.BulkProcessor().
-		After(func(execID int64, req []elastic.BulkableRequest, rsp *elastic.BulkResponse, err error) {
-			if err != nil {
-				log.Printf("bulk processing: executing [%d]: %v", execID, err)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: conf.Insecure}
+	if conf.CA == "" {
+		return transport, nil
+	}
 
-				return
-			}
-		}).
-		Name("esctl_bulk_processor").
-		Workers(20).
-		BulkActions(100).
-		BulkSize(2000 * 100).
-		FlushInterval(time.Second).
-		Do(context.Background())
+	pem, err := ioutil.ReadFile(conf.CA)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("read ca: %w", err)
 	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("read ca: no certificates found in %s", conf.CA)
+	}
+	transport.TLSClientConfig.RootCAs = pool
 
-	return &Bulker{BulkProcessor: processor}, nil
+	return transport, nil
 }
 
-// Add adds requests to remove given ids.
-func (b *Bulker) Rm(index string, ids ...string) {
-	for _, id := range ids {
-		b.rm(index, id)
+// Headers returns headers sent with every request to the cluster
+// including api key authorization of the user if set.
+func Headers(cluster app.Cluster, usr app.User) http.Header {
+	headers := make(http.Header, len(cluster.Headers)+1)
+	for k, v := range cluster.Headers {
+		headers.Set(k, v)
+	}
+	if usr.APIKey != "" {
+		headers.Set("Authorization", "ApiKey "+usr.APIKey)
 	}
-}
 
-func (b *Bulker) rm(index string, id string) {
-	b.Add(
-		elastic.NewBulkDeleteRequest().
-			Index(index).
-			Id(id),
-	)
+	return headers
 }
 
-func (b *Bulker) Save(docs ...backup.Document) {
-	for _, doc := range docs {
-		b.save(doc)
+// Reachable returns the first server of the cluster which responds.
+func Reachable(ctx context.Context, cluster app.Cluster, usr app.User) (string, Info, error) {
+	var errs []string
+	for _, server := range cluster.Servers {
+		single := cluster
+		single.Servers = []string{server}
+
+		cli, err := New(single, usr)
+		if err == nil {
+			var info Info
+			if info, err = cli.Info(ctx); err == nil {
+				return server, info, nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", server, err))
 	}
+	if len(errs) == 0 {
+		return "", Info{}, fmt.Errorf("no servers configured")
+	}
+
+	return "", Info{}, fmt.Errorf("no server is reachable: %s", strings.Join(errs, "; "))
+}
+
+// Info describes cluster.
+type Info struct {
+	Name    string `json:"cluster_name"`
+	UUID    string `json:"cluster_uuid"`
+	Version struct {
+		Number string `json:"number"`
+	} `json:"version"`
 }
 
-func (b *Bulker) save(doc backup.Document) {
-	b.Add(
-		elastic.NewBulkIndexRequest().
-			Index(doc.Index).
-			Id(doc.ID).
-			Doc(doc.Body),
-	)
+// Info returns basic information about cluster.
+func (cli *Client) Info(ctx context.Context) (info Info, err error) {
+	rsp, err := cli.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: "GET",
+		Path:   "/",
+	})
+	if err != nil {
+		return info, err
+	}
+
+	return info, json.Unmarshal(rsp.Body, &info)
 }
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// Cursor pages through documents using point in time and search_after.
+type Cursor struct {
+	cli       *Client
+	src       *elastic.SearchSource
+	indices   []string
+	keepAlive string
+
+	// PIT is an id of the point in time cursor reads from.
+	PIT string
+	// After holds sort values of the last read hit.
+	After []interface{}
+}
+
+// Cursor returns cursor over given indices.
+// Search source must define a sort to page with search_after.
+func (cli *Client) Cursor(src *elastic.SearchSource, keepAlive string, indices ...string) *Cursor {
+	if len(indices) == 0 {
+		indices = []string{"*"}
+	}
+
+	return &Cursor{
+		cli:       cli,
+		src:       src,
+		indices:   indices,
+		keepAlive: keepAlive,
+	}
+}
+
+// Open opens new point in time.
+func (c *Cursor) Open(ctx context.Context) error {
+	rsp, err := c.cli.OpenPointInTime(c.indices...).
+		KeepAlive(c.keepAlive).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+	c.PIT = rsp.Id
+
+	return nil
+}
+
+// Next returns next page of hits.
+// It returns io.EOF when there are no hits left.
+func (c *Cursor) Next(ctx context.Context) (*elastic.SearchResult, error) {
+	// search source accumulates search_after values,
+	// so request body is rebuilt on every page.
+	body, err := c.src.Source()
+	if err != nil {
+		return nil, err
+	}
+	src, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected search source %T", body)
+	}
+	src["pit"] = elastic.NewPointInTimeWithKeepAlive(c.PIT, c.keepAlive)
+	if len(c.After) > 0 {
+		src["search_after"] = c.After
+		// total is read from the first page only, counting it again makes every page slower.
+		src["track_total_hits"] = false
+	}
+
+	rsp, err := c.cli.Search().Source(src).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.PitId != "" {
+		c.PIT = rsp.PitId
+	}
+	if rsp.Hits == nil || len(rsp.Hits.Hits) == 0 {
+		return rsp, io.EOF
+	}
+	c.After = rsp.Hits.Hits[len(rsp.Hits.Hits)-1].Sort
+
+	return rsp, nil
+}
+
+// Close closes point in time.
+func (c *Cursor) Close(ctx context.Context) error {
+	if c.PIT == "" {
+		return nil
+	}
+	_, err := c.cli.ClosePointInTime(c.PIT).Do(ctx)
+
+	return err
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/unqnown/esctl/pkg/backup"
+)
+
+// private holds index settings assigned by cluster which can not be set on index creation.
+var private = []string{
+	"uuid",
+	"creation_date",
+	"provided_name",
+	"version",
+	"resize",
+	"shrink",
+	"verified_before_close",
+}
+
+// Indices returns configuration of given indices.
+func (cli *Client) Indices(ctx context.Context, indices ...string) (map[string]backup.Index, error) {
+	if len(indices) == 0 {
+		indices = []string{"*"}
+	}
+
+	rsp, err := cli.IndexGet(indices...).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := make(map[string]backup.Index, len(rsp))
+	for name, ind := range rsp {
+		if settings, ok := ind.Settings["index"].(map[string]interface{}); ok {
+			for _, s := range private {
+				delete(settings, s)
+			}
+			if routing, ok := settings["routing"].(map[string]interface{}); ok {
+				if allocation, ok := routing["allocation"].(map[string]interface{}); ok {
+					delete(allocation, "initial_recovery")
+				}
+			}
+		}
+		conf[name] = backup.Index{
+			Settings: ind.Settings,
+			Mappings: ind.Mappings,
+			Aliases:  ind.Aliases,
+		}
+	}
+
+	return conf, nil
+}
+
+// CreateIndices creates indices with given configuration under their new names.
+// Renamed indices are created without aliases, so aliases keep pointing to the original indices.
+// Indices renamed to the same name are created from the first one in name order.
+func (cli *Client) CreateIndices(ctx context.Context, indices map[string]backup.Index, rename func(string) string) error {
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sources := make(map[string]string, len(names))
+	for _, name := range names {
+		target := rename(name)
+		if source, dup := sources[target]; dup {
+			log.Printf("%q goes to %q created from %q", name, target, source)
+
+			continue
+		}
+		sources[target] = name
+
+		ind := indices[name]
+		if target != name {
+			ind.Aliases = nil
+		}
+
+		created, err := cli.CreateIndexFrom(ctx, target, ind)
+		if err != nil {
+			return fmt.Errorf("create %q: %w", target, err)
+		}
+		if created {
+			log.Printf("%q created", target)
+		}
+	}
+
+	return nil
+}
+
+// CreateIndexFrom creates index with given configuration.
+// It reports whether index has been created; existing index is left intact.
+func (cli *Client) CreateIndexFrom(ctx context.Context, name string, ind backup.Index) (bool, error) {
+	exists, err := cli.IndexExists(name).Do(ctx)
+	if err != nil || exists {
+		return false, err
+	}
+
+	// create index request accepts index configuration only.
+	body := map[string]interface{}{}
+	if len(ind.Settings) > 0 {
+		body["settings"] = ind.Settings
+	}
+	if len(ind.Mappings) > 0 {
+		body["mappings"] = ind.Mappings
+	}
+	if len(ind.Aliases) > 0 {
+		body["aliases"] = ind.Aliases
+	}
+
+	_, err = cli.CreateIndex(name).BodyJson(body).Do(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// Task describes long running task such as reindex.
+type Task struct {
+	ID        string
+	Completed bool
+	Action    string
+	// Description is usually a request the task serves.
+	Description string
+	Cancelled   bool
+	Running     time.Duration
+	Status      TaskStatus
+	// Response is a result of the completed task.
+	Response *TaskStatus
+	Error    *elastic.ErrorDetails
+}
+
+// TaskStatus is a progress of reindex, update by query and delete by query tasks.
+type TaskStatus struct {
+	Total             int64             `json:"total"`
+	Created           int64             `json:"created"`
+	Updated           int64             `json:"updated"`
+	Deleted           int64             `json:"deleted"`
+	Batches           int64             `json:"batches"`
+	VersionConflicts  int64             `json:"version_conflicts"`
+	Noops             int64             `json:"noops"`
+	RequestsPerSecond float64           `json:"requests_per_second"`
+	Failures          []json.RawMessage `json:"failures,omitempty"`
+}
+
+// Done returns amount of processed documents.
+func (s TaskStatus) Done() int64 {
+	return s.Created + s.Updated + s.Deleted + s.VersionConflicts + s.Noops
+}
+
+// Task returns task by its node:id.
+func (cli *Client) Task(ctx context.Context, id string) (t Task, err error) {
+	rsp, err := cli.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: "GET",
+		Path:   "/_tasks/" + url.PathEscape(id),
+	})
+	if err != nil {
+		return t, err
+	}
+
+	var body struct {
+		Completed bool `json:"completed"`
+		Task      struct {
+			Action             string     `json:"action"`
+			Status             TaskStatus `json:"status"`
+			Description        string     `json:"description"`
+			Cancelled          bool       `json:"cancelled"`
+			RunningTimeInNanos int64      `json:"running_time_in_nanos"`
+		} `json:"task"`
+		Response *TaskStatus           `json:"response"`
+		Error    *elastic.ErrorDetails `json:"error"`
+	}
+	if err := json.Unmarshal(rsp.Body, &body); err != nil {
+		return t, err
+	}
+
+	return Task{
+		ID:          id,
+		Completed:   body.Completed,
+		Action:      body.Task.Action,
+		Description: body.Task.Description,
+		Cancelled:   body.Task.Cancelled,
+		Running:     time.Duration(body.Task.RunningTimeInNanos),
+		Status:      body.Task.Status,
+		Response:    body.Response,
+		Error:       body.Error,
+	}, nil
+}
+
+// Watch polls task until it completes. Every poll is reported to fn.
+func (cli *Client) Watch(ctx context.Context, id string, every time.Duration, fn func(Task)) (Task, error) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+
+	for {
+		t, err := cli.Task(ctx, id)
+		if err != nil {
+			return t, err
+		}
+		fn(t)
+		if t.Completed {
+			return t, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// CancelTask cancels task.
+func (cli *Client) CancelTask(ctx context.Context, id string) error {
+	_, err := cli.TasksCancel().TaskId(id).Do(ctx)
+
+	return err
+}
+
+// Rethrottle changes requests per second of reindex, update by query or delete by query task.
+// Negative rate disables throttling.
+func (cli *Client) Rethrottle(ctx context.Context, t Task, rps float64) error {
+	var api string
+	switch {
+	case strings.HasSuffix(t.Action, "/reindex"):
+		api = "_reindex"
+	case strings.HasSuffix(t.Action, "/update/byquery"):
+		api = "_update_by_query"
+	case strings.HasSuffix(t.Action, "/delete/byquery"):
+		api = "_delete_by_query"
+	default:
+		return fmt.Errorf("task %s of %q action can't be rethrottled", t.ID, t.Action)
+	}
+
+	rate := "-1"
+	if rps >= 0 {
+		rate = strconv.FormatFloat(rps, 'f', -1, 64)
+	}
+
+	_, err := cli.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: "POST",
+		Path:   fmt.Sprintf("/%s/%s/_rethrottle", api, url.PathEscape(t.ID)),
+		Params: url.Values{"requests_per_second": []string{rate}},
+	})
+
+	return err
+}
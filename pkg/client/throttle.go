@@ -0,0 +1,166 @@
+package client
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// floor is the lowest rate throttle backs off to, docs per second.
+	floor = 10
+	// step is the rate throttle ramps up by after every accepted bulk, docs per second.
+	step = 50
+	// mb is a megabyte in bytes.
+	mb = 1 << 20
+)
+
+// Rate limits bulk throughput. Zero means unlimited.
+type Rate struct {
+	// Docs is a limit of documents per second.
+	Docs float64 `json:"docs"`
+	// MB is a limit of megabytes per second.
+	MB float64 `json:"mb"`
+}
+
+// throttle paces documents added to bulk processor.
+//
+// Besides static rate limits, in adaptive mode it halves the rate when
+// cluster rejects documents and increases it additively while cluster keeps up.
+type throttle struct {
+	mu sync.Mutex
+
+	rate      Rate
+	next      time.Time
+	nextBytes time.Time
+
+	adaptive  bool
+	limit     float64
+	backedOff time.Time
+
+	// observed throughput.
+	since    time.Time
+	added    float64
+	observed float64
+}
+
+// wait blocks until a document of given size may be added.
+func (t *throttle) wait(size int) {
+	t.mu.Lock()
+
+	now := time.Now()
+	t.observe(now)
+
+	d := pace(&t.next, now, 1, t.docs())
+	if b := pace(&t.nextBytes, now, float64(size), t.rate.MB*mb); b > d {
+		d = b
+	}
+
+	t.mu.Unlock()
+
+	time.Sleep(d)
+}
+
+// pace reserves time slot for n units at given rate
+// and returns how long to wait for it.
+func pace(next *time.Time, now time.Time, n, rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	if next.Before(now) {
+		*next = now
+	}
+	d := next.Sub(now)
+	*next = next.Add(time.Duration(n / rate * float64(time.Second)))
+
+	return d
+}
+
+// docs returns effective limit of documents per second.
+func (t *throttle) docs() float64 {
+	switch {
+	case t.limit == 0:
+		return t.rate.Docs
+	case t.rate.Docs == 0:
+		return t.limit
+	default:
+		return math.Min(t.limit, t.rate.Docs)
+	}
+}
+
+func (t *throttle) observe(now time.Time) {
+	if t.since.IsZero() {
+		t.since = now
+	}
+	t.added++
+	if elapsed := now.Sub(t.since); elapsed >= time.Second {
+		t.observed = t.added / elapsed.Seconds()
+		t.since, t.added = now, 0
+	}
+}
+
+// backoff halves the rate. Rejections of concurrent bulks
+// caused by the same overload result in a single back off.
+func (t *throttle) backoff() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !t.adaptive || now.Sub(t.backedOff) < cooldown {
+		return
+	}
+	t.backedOff = now
+
+	observed := t.observed
+	if elapsed := now.Sub(t.since).Seconds(); observed == 0 && elapsed > 0 {
+		// the first second has not passed yet.
+		observed = t.added / elapsed
+	}
+
+	base := t.docs()
+	if base == 0 || (observed > 0 && observed < base) {
+		base = observed
+	}
+
+	t.limit = math.Max(base/2, floor)
+}
+
+// rampUp increases the rate. Once the limit is well above observed
+// throughput cluster is not a bottleneck anymore and rate is unlimited.
+func (t *throttle) rampUp() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.adaptive || t.limit == 0 {
+		return
+	}
+
+	t.limit += step
+	if t.observed > 0 && t.limit > 2*t.observed {
+		t.limit = 0
+	}
+}
+
+// Limit returns effective limit of documents per second.
+func (t *throttle) Limit() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.docs()
+}
+
+// Rate returns static rate limits.
+func (t *throttle) Rate() Rate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rate
+}
+
+// SetRate sets static rate limits.
+func (t *throttle) SetRate(r Rate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rate = r
+}
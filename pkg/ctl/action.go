@@ -1,6 +1,8 @@
 package ctl
 
 import (
+	"fmt"
+
 	"github.com/unqnown/esctl/internal/app"
 	"github.com/unqnown/esctl/pkg/check"
 	"github.com/unqnown/esctl/pkg/client"
@@ -19,16 +21,32 @@ func NewAction(cmd CommandFunc) ActionFunc {
 		err = conf.SetContext(c.GlobalString("context"))
 		check.Fatal(err)
 
-		cst, usr, err := conf.Conn()
+		conn, err := Connect(conf, conf.Context)
 		check.Fatal(err)
 
-		conn, err := client.New(cst, usr)
-		check.Fatalf(err, "connect: %v", err)
-
 		check.Fatal(cmd(conf, conn, c))
 	}
 }
 
+// Connect connects to the cluster of the given context.
+func Connect(conf app.Config, ctx string) (*client.Client, error) {
+	if err := conf.SetContext(ctx); err != nil {
+		return nil, err
+	}
+
+	cst, usr, err := conf.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.New(cst, usr)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	return conn, nil
+}
+
 func Call(cmd CommandFunc, conf app.Config, conn *client.Client) ActionFunc {
 	return func(ctx *cli.Context) {
 		check.Fatal(cmd(conf, conn, ctx))
@@ -0,0 +1,105 @@
+package ctl
+
+import (
+	"log"
+	"os"
+
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/unqnown/esctl/pkg/bar"
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/urfave/cli"
+	"github.com/vbauerster/mpb/decor"
+)
+
+// BulkFlags configure bulk processor of a command.
+var BulkFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "workers",
+		Usage: "Amount of concurrent bulk requests",
+	},
+	cli.IntFlag{
+		Name:  "bulk-actions",
+		Usage: "Amount of documents per bulk request",
+	},
+	cli.IntFlag{
+		Name:  "bulk-size",
+		Usage: "Size of bulk request in `BYTES`",
+	},
+	cli.DurationFlag{
+		Name:  "flush-interval",
+		Usage: "Flush incomplete bulk requests every `INTERVAL`",
+	},
+	cli.BoolFlag{
+		Name:  "adaptive",
+		Usage: "Back off when cluster rejects documents and ramp up while it keeps up",
+	},
+	cli.Float64Flag{
+		Name:  "rate",
+		Usage: "Limit throughput to `N` documents per second",
+	},
+	cli.Float64Flag{
+		Name:  "rate-mb",
+		Usage: "Limit throughput to `N` megabytes per second",
+	},
+	cli.StringFlag{
+		Name:  "rate-file",
+		Usage: `Control rate limits live via json ` + "`FILE`" + ` like {"docs": 500, "mb": 2}, reread on change or SIGHUP`,
+	},
+}
+
+// Bulk returns bulk processor settings of the current cluster overridden by command flags.
+func Bulk(conf app.Config, c *cli.Context) (app.Bulk, error) {
+	settings, err := conf.Bulk()
+	if err != nil {
+		return app.Bulk{}, err
+	}
+
+	return settings.Merge(app.Bulk{
+		Workers:       c.Int("workers"),
+		Actions:       c.Int("bulk-actions"),
+		Size:          c.Int("bulk-size"),
+		FlushInterval: c.Duration("flush-interval"),
+		Adaptive:      c.Bool("adaptive"),
+	}), nil
+}
+
+// DeadLetter writes documents rejected by cluster to --dead-letter file if set.
+// Returned function closes the file.
+func DeadLetter(b *client.Bulker, c *cli.Context) (close func(), err error) {
+	path := c.String("dead-letter")
+	if path == "" {
+		return func() {}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	b.DeadLetter = f
+
+	return func() { f.Close() }, nil
+}
+
+// Decorators returns progress bar counters of bulker: failed documents
+// and current rate limit if it is controlled by flags or adaptive mode.
+func Decorators(b *client.Bulker, settings app.Bulk, c *cli.Context) []decor.Decorator {
+	extra := []decor.Decorator{bar.Counter("failed", b.Failed)}
+	if settings.Adaptive || c.IsSet("rate") || c.IsSet("rate-mb") || c.IsSet("rate-file") {
+		extra = append(extra, bar.Counter("docs/s limit", b.Limit))
+	}
+
+	return extra
+}
+
+// Rejected returns error which summarizes documents rejected by cluster if any.
+func Rejected(b *client.Bulker, c *cli.Context) error {
+	err := b.Err()
+	if err == nil {
+		return nil
+	}
+	if path := c.String("dead-letter"); path != "" {
+		log.Printf("rejected documents written to %q", path)
+	}
+
+	return err
+}
@@ -0,0 +1,109 @@
+package ctl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/unqnown/esctl/pkg/client"
+	"github.com/urfave/cli"
+)
+
+// poll is an interval of rate control file checks.
+const poll = time.Second
+
+// Limit applies rate limits to bulker and keeps them in sync with control file if any.
+// Missing control file is created with rate limits given by flags.
+// Returned function stops watching control file.
+func Limit(b *client.Bulker, c *cli.Context) (stop func(), err error) {
+	b.SetRate(client.Rate{Docs: c.Float64("rate"), MB: c.Float64("rate-mb")})
+
+	path := c.String("rate-file")
+	if path == "" {
+		return func() {}, nil
+	}
+
+	switch _, err := os.Stat(path); {
+	case os.IsNotExist(err):
+		if err := writeRate(path, b.Rate()); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	var mod time.Time
+
+	reload := func() {
+		s, err := os.Stat(path)
+		if err != nil {
+			log.Printf("rate file: %v", err)
+
+			return
+		}
+		if s.ModTime().Equal(mod) {
+			return
+		}
+		mod = s.ModTime()
+
+		r, err := readRate(path)
+		if err != nil {
+			log.Printf("rate file: %v", err)
+
+			return
+		}
+		b.SetRate(r)
+	}
+	reload()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(poll)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				mod = time.Time{}
+				reload()
+			case <-ticker.C:
+				reload()
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		ticker.Stop()
+		close(done)
+	}, nil
+}
+
+func readRate(path string) (client.Rate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return client.Rate{}, err
+	}
+
+	var r client.Rate
+	err = json.Unmarshal(data, &r)
+
+	return r, err
+}
+
+func writeRate(path string, r client.Rate) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
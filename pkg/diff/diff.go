@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Change describes field which differs.
+// Missing value is nil.
+type Change struct {
+	Path string
+	A, B interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s != %s", c.Path, format(c.A), format(c.B))
+}
+
+func format(v interface{}) string {
+	if v == nil {
+		return "<missing>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+// JSON compares two json documents field by field.
+func JSON(a, b []byte) ([]Change, error) {
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compare(va, vb), nil
+}
+
+func decode(data []byte) (v interface{}, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep long numbers intact.
+	dec.UseNumber()
+
+	return v, dec.Decode(&v)
+}
+
+// Compare compares two decoded json values field by field.
+// Nested fields are reported with dot separated paths, array elements with their indices.
+func Compare(a, b interface{}) []Change {
+	var changes []Change
+	compare("", a, b, &changes)
+
+	return changes
+}
+
+func compare(path string, a, b interface{}, changes *[]Change) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(va)+len(vb))
+		for k := range va {
+			keys = append(keys, k)
+		}
+		for k := range vb {
+			if _, ok := va[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			compare(join(path, k), va[k], vb[k], changes)
+		}
+
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			break
+		}
+
+		for i := range va {
+			compare(join(path, strconv.Itoa(i)), va[i], vb[i], changes)
+		}
+
+		return
+	case json.Number:
+		if vb, ok := b.(json.Number); ok && equal(va, vb) {
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, A: a, B: b})
+	}
+}
+
+// equal reports whether numbers are equal regardless of their notation, e.g. 1 and 1.0.
+func equal(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	ra, ok := new(big.Rat).SetString(a.String())
+	if !ok {
+		return false
+	}
+	rb, ok := new(big.Rat).SetString(b.String())
+	if !ok {
+		return false
+	}
+
+	return ra.Cmp(rb) == 0
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
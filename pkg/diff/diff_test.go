@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, nil},
+		{"number notation", `{"a":1}`, `{"a":1.0}`, nil},
+		{"long numbers", `{"a":12345678901234567890}`, `{"a":12345678901234567891}`, []string{"a: 12345678901234567890 != 12345678901234567891"}},
+		{"changed", `{"a":1}`, `{"a":2}`, []string{"a: 1 != 2"}},
+		{"missing", `{"a":1,"b":2}`, `{"a":1}`, []string{"b: 2 != <missing>"}},
+		{"added", `{}`, `{"c":"x"}`, []string{`c: <missing> != "x"`}},
+		{"nested", `{"u":{"e":"x","n":1}}`, `{"u":{"e":"y","n":1}}`, []string{`u.e: "x" != "y"`}},
+		{"array element", `{"a":[1,2]}`, `{"a":[1,3]}`, []string{"a.1: 2 != 3"}},
+		{"array length", `{"a":[1]}`, `{"a":[1,2]}`, []string{"a: [1] != [1,2]"}},
+		{"type", `{"a":{"b":1}}`, `{"a":"b"}`, []string{`a: {"b":1} != "b"`}},
+		{"sorted paths", `{"b":1,"a":1}`, `{"b":2,"a":2}`, []string{"a: 1 != 2", "b: 1 != 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := JSON([]byte(tt.a), []byte(tt.b))
+			if err != nil {
+				t.Fatalf("JSON: %v", err)
+			}
+
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSON(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONMalformed(t *testing.T) {
+	if _, err := JSON([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("JSON of malformed document: error expected")
+	}
+}
@@ -0,0 +1,140 @@
+package indexmap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule renames indices matching pattern.
+type Rule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// Parse parses rule of form pattern=replacement.
+//
+// Pattern is a glob where every * is captured and can be referred
+// in replacement as $1, $2 and so on, e.g. logs-*=archive-$1.
+// Pattern enclosed in slashes is a regular expression, e.g. /^logs-(\d+)$/=archive-$1.
+func Parse(rule string) (Rule, error) {
+	i := strings.LastIndex(rule, "=")
+	if i <= 0 {
+		return Rule{}, fmt.Errorf("malformed index mapping %q: pattern=replacement expected", rule)
+	}
+
+	return New(rule[:i], rule[i+1:])
+}
+
+// New returns rule which renames indices matching pattern.
+func New(pattern, repl string) (Rule, error) {
+	var expr string
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr = pattern[1 : len(pattern)-1]
+	} else {
+		parts := strings.Split(pattern, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		expr = "^" + strings.Join(parts, "(.*)") + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Rule{}, fmt.Errorf("index mapping pattern %q: %w", pattern, err)
+	}
+
+	return Rule{re: re, repl: braced(repl)}, nil
+}
+
+// braced encloses numbered references of replacement in braces,
+// so that $1_old refers to the first capture followed by _old rather than to group named 1_old.
+func braced(repl string) string {
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		if repl[i] != '$' || i+1 == len(repl) {
+			b.WriteByte(repl[i])
+
+			continue
+		}
+		if repl[i+1] == '$' {
+			// escaped dollar.
+			b.WriteString("$$")
+			i++
+
+			continue
+		}
+
+		j := i + 1
+		for j < len(repl) && repl[j] >= '0' && repl[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte('$')
+
+			continue
+		}
+		fmt.Fprintf(&b, "${%s}", repl[i+1:j])
+		i = j - 1
+	}
+
+	return b.String()
+}
+
+// Glob returns rule which renames indices matching glob pattern into destination
+// where every * is replaced with the text matched by corresponding * of pattern,
+// e.g. logs-2024.* into logs-v2-2024.*. Destination may refer to captures as $1 as well.
+func Glob(pattern, dst string) (Rule, error) {
+	if strings.Count(dst, "*") > strings.Count(pattern, "*") {
+		return Rule{}, fmt.Errorf("destination %q has more wildcards than %q", dst, pattern)
+	}
+
+	parts := strings.Split(dst, "*")
+	var repl strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			fmt.Fprintf(&repl, "${%d}", i)
+		}
+		repl.WriteString(part)
+	}
+
+	return New(pattern, repl.String())
+}
+
+// Map returns new index name and reports whether index matches rule.
+func (r Rule) Map(index string) (string, bool) {
+	match := r.re.FindStringSubmatchIndex(index)
+	if match == nil {
+		return index, false
+	}
+
+	return string(r.re.ExpandString(nil, r.repl, index, match)), true
+}
+
+// Mapping renames indices with the first matching rule.
+type Mapping []Rule
+
+// ParseAll parses list of rules.
+func ParseAll(rules ...string) (Mapping, error) {
+	m := make(Mapping, 0, len(rules))
+	for _, rule := range rules {
+		r, err := Parse(rule)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, r)
+	}
+
+	return m, nil
+}
+
+// Map returns new index name. Index not matching any rule is left intact.
+func (m Mapping) Map(index string) string {
+	for _, r := range m {
+		if name, ok := r.Map(index); ok {
+			return name
+		}
+	}
+
+	return index
+}
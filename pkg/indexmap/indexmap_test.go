@@ -0,0 +1,91 @@
+package indexmap
+
+import "testing"
+
+func TestRuleMap(t *testing.T) {
+	tests := []struct {
+		rule  string
+		index string
+		want  string
+		ok    bool
+	}{
+		{"logs-*=archive-$1", "logs-2023.01", "archive-2023.01", true},
+		{"logs-2023.*=archive-$1_old", "logs-2023.01", "archive-01_old", true},
+		{"logs-2023.*=archive-$1x", "logs-2023.01", "archive-01x", true},
+		{"logs-*.*=$2-$1", "logs-2023.01", "01-2023", true},
+		{"logs-*=price$$1", "logs-a", "price$1", true},
+		{"logs-*=cost$", "logs-a", "cost$", true},
+		{"logs-*=archive", "logs-a", "archive", true},
+		{"logs-*=archive-$1", "metrics-a", "metrics-a", false},
+		{"logs.*=x-$1", "logsA1", "logsA1", false},
+		{"/^logs-(\\d+)$/=archive-$1", "logs-42", "archive-42", true},
+		{"/^logs-(\\d+)$/=archive-$1", "logs-x", "logs-x", false},
+		{"/^(?P<name>.+)-v1$/=${name}-v2", "logs-v1", "logs-v2", true},
+		{"a=b=c", "a=b", "c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.index, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got, ok := r.Map(tt.index)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Map(%q) = %q, %v, want %q, %v", tt.index, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, rule := range []string{"", "logs-*", "=archive", "/(/=x"} {
+		if _, err := Parse(rule); err == nil {
+			t.Errorf("Parse(%q): error expected", rule)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, dst string
+		index        string
+		want         string
+	}{
+		{"logs-2024.*", "logs-v2-2024.*", "logs-2024.05", "logs-v2-2024.05"},
+		{"logs-*.*", "*-*-copy", "logs-2024.05", "2024-05-copy"},
+		{"logs-*", "v2-$1_old", "logs-a", "v2-a_old"},
+		{"logs-*", "archive", "logs-a", "archive"},
+	}
+
+	for _, tt := range tests {
+		r, err := Glob(tt.pattern, tt.dst)
+		if err != nil {
+			t.Fatalf("Glob(%q, %q): %v", tt.pattern, tt.dst, err)
+		}
+		if got, _ := r.Map(tt.index); got != tt.want {
+			t.Errorf("Glob(%q, %q).Map(%q) = %q, want %q", tt.pattern, tt.dst, tt.index, got, tt.want)
+		}
+	}
+
+	if _, err := Glob("logs-*", "*-*"); err == nil {
+		t.Error("Glob with more wildcards in destination: error expected")
+	}
+}
+
+func TestMapping(t *testing.T) {
+	m, err := ParseAll("logs-2023.*=old-$1", "logs-*=new-$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, want := range map[string]string{
+		"logs-2023.01": "old-01",
+		"logs-2024.01": "new-2024.01",
+		"metrics":      "metrics",
+	} {
+		if got := m.Map(index); got != want {
+			t.Errorf("Map(%q) = %q, want %q", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/unqnown/esctl/internal/app"
+	"github.com/urfave/cli"
+)
+
+// Flags configures query.
+var Flags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "query",
+		Usage: "Query `DSL`: inline json, json file or name of the query saved in .query dir",
+	},
+	cli.StringFlag{
+		Name:  "q",
+		Usage: "Lucene query string `QUERY`, e.g. 'status:error AND age:>30'",
+	},
+}
+
+// Parse builds query from flags. Both queries must match if set.
+// It returns nil query if none is set.
+func Parse(conf app.Config, c *cli.Context) (elastic.Query, error) {
+	var queries []elastic.Query
+	if dsl := c.String("query"); dsl != "" {
+		q, err := Load(conf, dsl)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	if qs := c.String("q"); qs != "" {
+		if file(qs) {
+			// -q used to be a query file, so old command lines must not turn into query string silently.
+			return nil, fmt.Errorf("-q %s looks like a query file: -q is a query string now, use --query %s", qs, qs)
+		}
+		queries = append(queries, elastic.NewQueryStringQuery(qs))
+	}
+
+	switch len(queries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return queries[0], nil
+	default:
+		return elastic.NewBoolQuery().Must(queries...), nil
+	}
+}
+
+// Load resolves query DSL. It is either an inline json,
+// a json file or a name of the query saved in .query dir.
+func Load(conf app.Config, dsl string) (elastic.Query, error) {
+	if strings.HasPrefix(strings.TrimSpace(dsl), "{") {
+		return raw([]byte(dsl))
+	}
+
+	path := dsl
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if path, err = named(conf, dsl); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return raw(data)
+}
+
+// file reports whether query string refers to a query file.
+func file(qs string) bool {
+	if strings.HasSuffix(qs, ".json") {
+		return true
+	}
+	s, err := os.Stat(qs)
+
+	return err == nil && !s.IsDir()
+}
+
+// named returns path of the saved query.
+func named(conf app.Config, name string) (string, error) {
+	for _, path := range []string{
+		filepath.Join(conf.Home, app.QueryDir, name),
+		filepath.Join(conf.Home, app.QueryDir, name+".json"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("query %q: neither file nor saved query", name)
+}
+
+// raw checks query is valid json. Query is sent as is.
+func raw(data []byte) (elastic.Query, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("malformed query: %s", data)
+	}
+
+	return elastic.NewRawStringQuery(string(data)), nil
+}
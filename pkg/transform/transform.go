@@ -0,0 +1,223 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/unqnown/esctl/pkg/backup"
+	"github.com/urfave/cli"
+)
+
+// ID is a pseudo field which refers to document id.
+const ID = "_id"
+
+var ErrNotObject = errors.New("document body is not an object")
+
+// Flags configures transformation pipeline.
+var Flags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "rename",
+		Usage: "Rename field: `old=new`. Nested fields are dot separated, _id refers to document id",
+	},
+	cli.StringSliceFlag{
+		Name:  "set",
+		Usage: "Set field: `field=value`. Value is parsed as json, falls back to string",
+	},
+	cli.StringSliceFlag{
+		Name:  "drop",
+		Usage: "Drop `field`",
+	},
+}
+
+// Rule transforms document.
+type Rule func(doc *backup.Document) error
+
+// Pipeline applies rules one by one.
+type Pipeline []Rule
+
+// Apply applies pipeline to the document.
+func (p Pipeline) Apply(doc *backup.Document) error {
+	for _, rule := range p {
+		if err := rule(doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Parse builds pipeline from flags. Rules are applied in order: rename, set, drop.
+func Parse(c *cli.Context) (p Pipeline, err error) {
+	for _, r := range c.StringSlice("rename") {
+		from, to, err := split(r)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		p = append(p, Rename(from, to))
+	}
+	for _, s := range c.StringSlice("set") {
+		field, value, err := split(s)
+		if err != nil {
+			return nil, fmt.Errorf("set: %w", err)
+		}
+		p = append(p, Set(field, Value(value)))
+	}
+	for _, field := range c.StringSlice("drop") {
+		p = append(p, Drop(field))
+	}
+
+	return p, nil
+}
+
+// Describe returns rules set by flags, nil if documents are kept as is.
+func Describe(c *cli.Context) *backup.Transform {
+	t := backup.Transform{
+		Rename: c.StringSlice("rename"),
+		Set:    c.StringSlice("set"),
+		Drop:   c.StringSlice("drop"),
+	}
+	if t.Empty() {
+		return nil
+	}
+
+	return &t
+}
+
+func split(rule string) (string, string, error) {
+	kv := strings.SplitN(rule, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", "", fmt.Errorf("malformed rule %q", rule)
+	}
+
+	return kv[0], kv[1], nil
+}
+
+// Value parses value as json, falls back to string.
+func Value(value string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return value
+	}
+
+	return v
+}
+
+// Rename moves field value to another field.
+func Rename(from, to string) Rule {
+	return func(doc *backup.Document) error {
+		v, found, err := get(doc, from)
+		if err != nil || !found {
+			return err
+		}
+		if err := del(doc, from); err != nil {
+			return err
+		}
+
+		return set(doc, to, v)
+	}
+}
+
+// Set sets field value.
+func Set(field string, value interface{}) Rule {
+	return func(doc *backup.Document) error { return set(doc, field, value) }
+}
+
+// Drop removes field.
+func Drop(field string) Rule {
+	return func(doc *backup.Document) error { return del(doc, field) }
+}
+
+func body(doc *backup.Document) (map[string]interface{}, error) {
+	if raw, ok := doc.Body.(json.RawMessage); ok {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&doc.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	obj, ok := doc.Body.(map[string]interface{})
+	if !ok {
+		return nil, ErrNotObject
+	}
+
+	return obj, nil
+}
+
+func get(doc *backup.Document, field string) (interface{}, bool, error) {
+	if field == ID {
+		return doc.ID, doc.ID != "", nil
+	}
+
+	obj, err := body(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	path := strings.Split(field, ".")
+	for _, key := range path[:len(path)-1] {
+		var ok bool
+		if obj, ok = obj[key].(map[string]interface{}); !ok {
+			return nil, false, nil
+		}
+	}
+	v, found := obj[path[len(path)-1]]
+
+	return v, found, nil
+}
+
+func set(doc *backup.Document, field string, value interface{}) error {
+	if field == ID {
+		doc.ID = fmt.Sprint(value)
+
+		return nil
+	}
+
+	obj, err := body(doc)
+	if err != nil {
+		return err
+	}
+
+	path := strings.Split(field, ".")
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+
+	return nil
+}
+
+func del(doc *backup.Document, field string) error {
+	if field == ID {
+		doc.ID = ""
+
+		return nil
+	}
+
+	obj, err := body(doc)
+	if err != nil {
+		return err
+	}
+
+	path := strings.Split(field, ".")
+	for _, key := range path[:len(path)-1] {
+		var ok bool
+		if obj, ok = obj[key].(map[string]interface{}); !ok {
+			return nil
+		}
+	}
+	delete(obj, path[len(path)-1])
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/unqnown/esctl/pkg/backup"
+)
+
+func doc(body string) backup.Document {
+	return backup.Document{ID: "1", Index: "logs", Body: json.RawMessage(body)}
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline Pipeline
+		body     string
+		id       string
+		want     string
+	}{
+		{"rename", Pipeline{Rename("a", "b")}, `{"a":1}`, "1", `{"b":1}`},
+		{"rename nested", Pipeline{Rename("user.email", "contact.email")}, `{"user":{"email":"x","name":"y"}}`, "1", `{"contact":{"email":"x"},"user":{"name":"y"}}`},
+		{"rename missing", Pipeline{Rename("a", "b")}, `{"c":1}`, "1", `{"c":1}`},
+		{"rename to id", Pipeline{Rename("key", ID)}, `{"key":"k1","n":1}`, "k1", `{"n":1}`},
+		{"rename id to field", Pipeline{Rename(ID, "key")}, `{"n":1}`, "", `{"key":"1","n":1}`},
+		{"set", Pipeline{Set("a.b", Value("2"))}, `{"a":1}`, "1", `{"a":{"b":2}}`},
+		{"set string", Pipeline{Set("env", Value("prod"))}, `{}`, "1", `{"env":"prod"}`},
+		{"set id", Pipeline{Set(ID, Value("7"))}, `{}`, "7", `{}`},
+		{"drop", Pipeline{Drop("a")}, `{"a":1,"b":2}`, "1", `{"b":2}`},
+		{"drop nested missing", Pipeline{Drop("a.b.c")}, `{"a":1}`, "1", `{"a":1}`},
+		{"in order", Pipeline{Rename("a", "b"), Set("a", Value("true")), Drop("b")}, `{"a":1}`, "1", `{"a":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := doc(tt.body)
+			if err := tt.pipeline.Apply(&d); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			got, err := json.Marshal(d.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || d.ID != tt.id {
+				t.Errorf("Apply(%s) = %s id %q, want %s id %q", tt.body, got, d.ID, tt.want, tt.id)
+			}
+		})
+	}
+}
+
+func TestPipelineNotObject(t *testing.T) {
+	d := doc(`[1,2]`)
+	if err := (Pipeline{Drop("a")}).Apply(&d); !errors.Is(err, ErrNotObject) {
+		t.Errorf("Apply to array = %v, want %v", err, ErrNotObject)
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"1", json.Number("1")},
+		{"12345678901234567890", json.Number("12345678901234567890")},
+		{"true", true},
+		{"null", nil},
+		{`"quoted"`, "quoted"},
+		{`{"a":1}`, map[string]interface{}{"a": json.Number("1")}},
+		{"plain text", "plain text"},
+		{"1 2", "1 2"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Value(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Value(%q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}